@@ -4,9 +4,11 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -21,10 +23,28 @@ type (
 	Build struct {
 		// enables adding a custom host-to-IP mapping (host:ip)
 		AddHosts []string
+		// enables deriving tags from the Vela build metadata
+		AutoTag bool
 		// enables setting build-time variables
 		BuildArgs []string
-		// enables setting images to consider as cache sources
-		CacheFrom string
+		// enables setting a dotenv file to read build-time variables from
+		BuildArgsFile string
+		// enables forwarding environment variables as build-time variables: NAME or ARG=NAME
+		BuildArgsFromEnv []string
+		// enables redacting the values of the build-time variables with the names
+		BuildArgsSecret []string
+		// used for translating the named build contexts
+		BuildContexts map[string]string
+		// enables setting additional named build contexts (only if BuildKit enabled): name=path|docker-image://image|url
+		BuildContextsRaw string
+		// enables setting images or BuildKit caches to consider as cache sources
+		CacheFrom []string
+		// enables setting raw cache sources
+		CacheFromRaw string
+		// enables setting BuildKit caches to export the build cache to (only if buildx enabled)
+		CacheTo []string
+		// enables setting raw cache destinations
+		CacheToRaw string
 		// enables setting an optional parent cgroup for the container
 		CGroupParent string
 		// enables setting compression the build context using gzip
@@ -37,6 +57,8 @@ type (
 		CPURaw string
 		// enables skipping image verification (default true)
 		DisableContentTrust bool
+		// used for running the build against the daemon
+		Engine Engine
 		// enables setting the name of the Dockerfile (Default is 'PATH/Dockerfile')
 		File string
 		// enables setting always remove on intermediate containers
@@ -49,6 +71,8 @@ type (
 		Label *Label
 		// enables setting metadata for an image
 		Labels []string
+		// enables writing the build result metadata to a file (only if buildx enabled)
+		MetadataFile string
 		// enables setting a memory limit
 		Memory []string
 		// enables setting a swap limit equal to memory plus swap: '-1' to enable unlimited swap
@@ -61,18 +85,28 @@ type (
 		Output string
 		// enables setting a platform if server is multi-platform capable
 		Platform string
+		// enables setting multiple platforms to publish as a manifest list (only if BuildKit enabled)
+		Platforms []string
 		// enables setting type of progress output - options (auto|plain|tty)
 		Progress string
 		// enables always attempting to pull a newer version of the image
 		Pull bool
+		// enables pushing the image directly from the buildx builder
+		Publish bool
 		// enables suppressing the build output and print image ID on success
 		Quiet bool
 		// enables removing the intermediate containers after a successful build (default true)
 		Remove bool
 		// enables setting the Docker repository name for the image
 		Repo string
-		// enables setting a secret file to expose to the build (only if BuildKit enabled): id=mysecret,src=/local/secret
-		Secret string
+		// used for retrying the build when the image is published from the builder
+		Retry *Retry
+		// used for translating the build arguments forwarded from environment variables
+		envArgs []string
+		// used for translating the secrets to expose to the build
+		Secrets []*Secret
+		// enables setting secrets to expose to the build (only if BuildKit enabled): id=mysecret,src=/local/secret
+		SecretsRaw string
 		// enables setting security options
 		SecurityOpts []string
 		// enables setting the size of /dev/shm
@@ -89,6 +123,9 @@ type (
 		Target string
 		// enables setting ulimit options (default [])
 		Ulimits []string
+
+		// templates the rendered tags were created from
+		templates map[string]string
 	}
 
 	// CPU represents the "cpu" prefixed flags within the "docker build" command.
@@ -109,14 +146,22 @@ type (
 	Label struct {
 		// author from the source commit
 		AuthorEmail string
+		// branch from the source commit
+		Branch string
 		// commit sha from the source commit
 		Commit string
 		// timestamp when the image was built
 		Created string
+		// default branch of the repository
+		DefaultBranch string
+		// event that triggered the build
+		Event string
 		// full name of the repository
 		FullName string
 		// build number from vela
 		Number int
+		// tag from the source commit
+		Tag string
 		// direct url of the repository
 		URL string
 	}
@@ -134,6 +179,16 @@ var buildFlags = []cli.Flag{
 			cli.File("/vela/secrets/docker/add_hosts"),
 		),
 	},
+	&cli.BoolFlag{
+		Name:  "build.auto-tag",
+		Usage: "enables deriving tags from the Vela build metadata",
+		Sources: cli.NewValueSourceChain(
+			cli.EnvVar("PARAMETER_AUTO_TAG"),
+			cli.EnvVar("DOCKER_AUTO_TAG"),
+			cli.File("/vela/parameters/docker/auto_tag"),
+			cli.File("/vela/secrets/docker/auto_tag"),
+		),
+	},
 	&cli.StringSliceFlag{
 		Name:  "build.build-args",
 		Usage: "enables setting build time arguments for the dockerfile",
@@ -144,6 +199,46 @@ var buildFlags = []cli.Flag{
 			cli.File("/vela/secrets/docker/build_args"),
 		),
 	},
+	&cli.StringFlag{
+		Name:  "build.build-args-file",
+		Usage: "enables setting a dotenv file to read build time arguments from",
+		Sources: cli.NewValueSourceChain(
+			cli.EnvVar("PARAMETER_BUILD_ARGS_FILE"),
+			cli.EnvVar("DOCKER_BUILD_ARGS_FILE"),
+			cli.File("/vela/parameters/docker/build_args_file"),
+			cli.File("/vela/secrets/docker/build_args_file"),
+		),
+	},
+	&cli.StringSliceFlag{
+		Name:  "build.build-args-from-env",
+		Usage: "enables forwarding environment variables as build time arguments",
+		Sources: cli.NewValueSourceChain(
+			cli.EnvVar("PARAMETER_BUILD_ARGS_FROM_ENV"),
+			cli.EnvVar("DOCKER_BUILD_ARGS_FROM_ENV"),
+			cli.File("/vela/parameters/docker/build_args_from_env"),
+			cli.File("/vela/secrets/docker/build_args_from_env"),
+		),
+	},
+	&cli.StringSliceFlag{
+		Name:  "build.build-args-secret",
+		Usage: "enables redacting the values of build time arguments from the output",
+		Sources: cli.NewValueSourceChain(
+			cli.EnvVar("PARAMETER_BUILD_ARGS_SECRET"),
+			cli.EnvVar("DOCKER_BUILD_ARGS_SECRET"),
+			cli.File("/vela/parameters/docker/build_args_secret"),
+			cli.File("/vela/secrets/docker/build_args_secret"),
+		),
+	},
+	&cli.StringFlag{
+		Name:  "build.build-contexts",
+		Usage: "enables setting additional named build contexts",
+		Sources: cli.NewValueSourceChain(
+			cli.EnvVar("PARAMETER_BUILD_CONTEXTS"),
+			cli.EnvVar("DOCKER_BUILD_CONTEXTS"),
+			cli.File("/vela/parameters/docker/build_contexts"),
+			cli.File("/vela/secrets/docker/build_contexts"),
+		),
+	},
 	&cli.StringFlag{
 		Name:  "build.cache-from",
 		Usage: "enables setting images to consider as cache sources",
@@ -154,6 +249,16 @@ var buildFlags = []cli.Flag{
 			cli.File("/vela/secrets/docker/cache_from"),
 		),
 	},
+	&cli.StringFlag{
+		Name:  "build.cache-to",
+		Usage: "enables setting BuildKit caches to export the build cache to",
+		Sources: cli.NewValueSourceChain(
+			cli.EnvVar("PARAMETER_CACHE_TO"),
+			cli.EnvVar("DOCKER_CACHE_TO"),
+			cli.File("/vela/parameters/docker/cache_to"),
+			cli.File("/vela/secrets/docker/cache_to"),
+		),
+	},
 	&cli.StringFlag{
 		Name:  "build.cgroup-parent",
 		Usage: "enables setting an optional parent cgroup for the container",
@@ -315,6 +420,16 @@ var buildFlags = []cli.Flag{
 			cli.File("/vela/secrets/docker/platform"),
 		),
 	},
+	&cli.StringSliceFlag{
+		Name:  "build.platforms",
+		Usage: "enables setting multiple platforms to publish as a manifest list (only if BuildKit enabled)",
+		Sources: cli.NewValueSourceChain(
+			cli.EnvVar("PARAMETER_PLATFORMS"),
+			cli.EnvVar("DOCKER_PLATFORMS"),
+			cli.File("/vela/parameters/docker/platforms"),
+			cli.File("/vela/secrets/docker/platforms"),
+		),
+	},
 	&cli.StringFlag{
 		Name:  "build.progress",
 		Usage: "enables setting type of progress output - options (auto|plain|tty)",
@@ -367,12 +482,16 @@ var buildFlags = []cli.Flag{
 		),
 	},
 	&cli.StringFlag{
-		Name:  "build.secret",
-		Usage: "set a secret file to expose to the build (only if BuildKit enabled): id=mysecret,src=/local/secret",
+		Name:  "build.secrets",
+		Usage: "set secrets to expose to the build (only if BuildKit enabled): id=mysecret,src=/local/secret",
 		Sources: cli.NewValueSourceChain(
+			cli.EnvVar("PARAMETER_SECRETS"),
+			cli.EnvVar("DOCKER_SECRETS"),
 			cli.EnvVar("PARAMETER_SECRET"),
 			cli.EnvVar("DOCKER_SECRET"),
+			cli.File("/vela/parameters/docker/secrets"),
 			cli.File("/vela/parameters/docker/secret"),
+			cli.File("/vela/secrets/docker/secrets"),
 			cli.File("/vela/secrets/docker/secret"),
 		),
 	},
@@ -463,6 +582,11 @@ var buildFlags = []cli.Flag{
 		Usage:   "author from the source commit",
 		Sources: cli.EnvVars("VELA_BUILD_AUTHOR_EMAIL"),
 	},
+	&cli.StringFlag{
+		Name:    "label.branch",
+		Usage:   "branch from the source commit",
+		Sources: cli.EnvVars("VELA_BUILD_BRANCH"),
+	},
 	&cli.StringFlag{
 		Name:    "label.commit",
 		Usage:   "commit sha from the source commit",
@@ -473,11 +597,26 @@ var buildFlags = []cli.Flag{
 		Usage:   "build number",
 		Sources: cli.EnvVars("VELA_BUILD_NUMBER"),
 	},
+	&cli.StringFlag{
+		Name:    "label.default-branch",
+		Usage:   "default branch of the repository",
+		Sources: cli.EnvVars("VELA_REPO_BRANCH"),
+	},
+	&cli.StringFlag{
+		Name:    "label.event",
+		Usage:   "event that triggered the build",
+		Sources: cli.EnvVars("VELA_BUILD_EVENT"),
+	},
 	&cli.StringFlag{
 		Name:    "label.full-name",
 		Usage:   "full name of the repository",
 		Sources: cli.EnvVars("VELA_REPO_FULL_NAME"),
 	},
+	&cli.StringFlag{
+		Name:    "label.tag",
+		Usage:   "tag from the source commit",
+		Sources: cli.EnvVars("VELA_BUILD_TAG"),
+	},
 	&cli.StringFlag{
 		Name:    "label.url",
 		Usage:   "direct url of the repository",
@@ -495,6 +634,9 @@ func (b *Build) Command(ctx context.Context) *exec.Cmd {
 	// variable to store flags for command
 	var flags []string
 
+	// capture if the flags only supported by the classic builder apply
+	classic := !b.Buildx()
+
 	// iterate through the additional hosts provided
 	for _, a := range b.AddHosts {
 		// add flag for AddHosts from provided build command
@@ -507,10 +649,35 @@ func (b *Build) Command(ctx context.Context) *exec.Cmd {
 		flags = append(flags, "--build-arg", b)
 	}
 
-	// check if CacheFrom is provided
-	if len(b.CacheFrom) > 0 {
+	// iterate through the build arguments forwarded from environment variables
+	for _, a := range b.envArgs {
+		key, _, _ := strings.Cut(a, "=")
+
+		// add flag for BuildArgsFromEnv from provided build command
+		//
+		// the docker CLI reads the value from its environment
+		flags = append(flags, "--build-arg", key)
+	}
+
+	// iterate through the build contexts provided
+	for _, c := range buildContextFlags(b.BuildContexts) {
+		// add flag for BuildContexts from provided build command
+		flags = append(flags, "--build-context", c)
+	}
+
+	// iterate through the cache sources provided
+	for _, c := range b.CacheFrom {
 		// add flag for CacheFrom from provided build command
-		flags = append(flags, "--cache-from", b.CacheFrom)
+		flags = append(flags, "--cache-from", c)
+	}
+
+	// check if cache destinations apply
+	if !classic {
+		// iterate through the cache destinations provided
+		for _, c := range b.CacheTo {
+			// add flag for CacheTo from provided build command
+			flags = append(flags, "--cache-to", c)
+		}
 	}
 
 	// check if CGroupParent is provided
@@ -520,16 +687,19 @@ func (b *Build) Command(ctx context.Context) *exec.Cmd {
 	}
 
 	// check if Compress is provided
-	if b.Compress {
+	if b.Compress && classic {
 		// add flag for Compress from provided build command
 		flags = append(flags, "--compress")
 	}
 
-	// add flags for CPU configuration
-	flags = append(flags, b.CPU.Flags()...)
+	// check if CPU configuration applies
+	if classic {
+		// add flags for CPU configuration
+		flags = append(flags, b.CPU.Flags()...)
+	}
 
 	// check if DisableContentTrust is provided
-	if b.DisableContentTrust {
+	if b.DisableContentTrust && classic {
 		// add flag for DisableContentTrust from provided build command
 		flags = append(flags, "--disable-content-trust")
 	}
@@ -541,7 +711,7 @@ func (b *Build) Command(ctx context.Context) *exec.Cmd {
 	}
 
 	// check if ForceRM is provided
-	if b.ForceRM {
+	if b.ForceRM && classic {
 		// add flag for ForceRM from provided build command
 		flags = append(flags, "--force-rm")
 	}
@@ -553,7 +723,7 @@ func (b *Build) Command(ctx context.Context) *exec.Cmd {
 	}
 
 	// check if Isolation is provided
-	if len(b.Isolation) > 0 {
+	if len(b.Isolation) > 0 && classic {
 		// add flag for Isolation from provided build command
 		flags = append(flags, "--isolation", b.Isolation)
 	}
@@ -564,16 +734,33 @@ func (b *Build) Command(ctx context.Context) *exec.Cmd {
 		flags = append(flags, "--label", l)
 	}
 
-	// iterate through the memory arguments provided
-	for _, m := range b.Memory {
-		// add flag for Memory from provided build command
-		flags = append(flags, "--memory", m)
+	// check if the image must be loaded into the daemon from the builder
+	//
+	// the image is pushed from the daemon when it isn't published by the builder
+	if !classic && !b.Publish && len(b.Platforms) <= 1 && len(b.Output) == 0 {
+		// add flag for loading the image from provided build command
+		flags = append(flags, "--load")
 	}
 
-	// iterate through the memory swap arguments provided
-	for _, m := range b.MemorySwaps {
-		// add flag for Memory Swaps from provided build command
-		flags = append(flags, "--memory-swap", m)
+	// check if MetadataFile is provided
+	if len(b.MetadataFile) > 0 && !classic {
+		// add flag for MetadataFile from provided build command
+		flags = append(flags, "--metadata-file", b.MetadataFile)
+	}
+
+	// check if memory configuration applies
+	if classic {
+		// iterate through the memory arguments provided
+		for _, m := range b.Memory {
+			// add flag for Memory from provided build command
+			flags = append(flags, "--memory", m)
+		}
+
+		// iterate through the memory swap arguments provided
+		for _, m := range b.MemorySwaps {
+			// add flag for Memory Swaps from provided build command
+			flags = append(flags, "--memory-swap", m)
+		}
 	}
 
 	// check if Network is provided
@@ -600,6 +787,12 @@ func (b *Build) Command(ctx context.Context) *exec.Cmd {
 		flags = append(flags, "--platform", b.Platform)
 	}
 
+	// check if Platforms are provided
+	if len(b.Platforms) > 0 {
+		// add flag for Platforms from provided build command
+		flags = append(flags, "--platform", strings.Join(b.Platforms, ","))
+	}
+
 	// check if Progress is provided
 	if len(b.Progress) > 0 {
 		// add flag for Progress from provided build command
@@ -612,6 +805,12 @@ func (b *Build) Command(ctx context.Context) *exec.Cmd {
 		flags = append(flags, "--pull")
 	}
 
+	// check if Publish is provided
+	if b.Publish && !classic {
+		// add flag for Publish from provided build command
+		flags = append(flags, "--push")
+	}
+
 	// check if Quiet is provided
 	if b.Quiet {
 		// add flag for Quiet from provided build command
@@ -619,21 +818,24 @@ func (b *Build) Command(ctx context.Context) *exec.Cmd {
 	}
 
 	// check if Remove is provided
-	if b.Remove {
+	if b.Remove && classic {
 		// add flag for Remove from provided build command
 		flags = append(flags, "--rm")
 	}
 
-	// check if Secret is provided
-	if len(b.Secret) > 0 {
-		// add flag for secret from provided build command
-		flags = append(flags, "--secret", b.Secret)
+	// iterate through the secrets provided
+	for _, secret := range b.Secrets {
+		// add flag for Secrets from provided build command
+		flags = append(flags, "--secret", secret.Flag())
 	}
 
-	// iterate through the security options provided
-	for _, s := range b.SecurityOpts {
-		// add flag for SecurityOpts from provided build command
-		flags = append(flags, "--security-opt", s)
+	// check if security options apply
+	if classic {
+		// iterate through the security options provided
+		for _, s := range b.SecurityOpts {
+			// add flag for SecurityOpts from provided build command
+			flags = append(flags, "--security-opt", s)
+		}
 	}
 
 	// iterate through the SHM sizes provided
@@ -643,7 +845,7 @@ func (b *Build) Command(ctx context.Context) *exec.Cmd {
 	}
 
 	// check if Squash is provided
-	if b.Squash {
+	if b.Squash && classic {
 		// add flag for Squash from provided build command
 		flags = append(flags, "--squash")
 	}
@@ -655,21 +857,13 @@ func (b *Build) Command(ctx context.Context) *exec.Cmd {
 	}
 
 	// check if Stream is provided
-	if b.Stream {
+	if b.Stream && classic {
 		// add flag for Stream from provided build command
 		flags = append(flags, "--stream")
 	}
 
 	// iterate through the tags provided
 	for _, t := range b.Tags {
-		// check if a Docker repository was provided
-		if len(b.Repo) > 0 {
-			// check if the tag already has the repo in it
-			if !strings.Contains(t, b.Repo) {
-				t = fmt.Sprintf("%s:%s", b.Repo, t)
-			}
-		}
-
 		// add flag for Tags from provided build command
 		flags = append(flags, "--tag", t)
 	}
@@ -691,7 +885,22 @@ func (b *Build) Command(ctx context.Context) *exec.Cmd {
 
 	//nolint:gosec // this functionality is not exploitable the way
 	// the plugin accepts configuration
-	return exec.CommandContext(ctx, _docker, append([]string{buildAction}, flags...)...)
+	cmd := exec.CommandContext(ctx, _docker, append([]string{buildAction}, flags...)...)
+
+	// check if the build runs with buildx
+	if !classic {
+		//nolint:gosec // this functionality is not exploitable the way
+		// the plugin accepts configuration
+		cmd = exec.CommandContext(ctx, _docker, append([]string{buildxAction, buildAction}, flags...)...)
+	}
+
+	// check if build arguments are forwarded from environment variables
+	if len(b.envArgs) > 0 {
+		// provide the values to the docker CLI without adding them to the command
+		cmd.Env = append(os.Environ(), b.envArgs...)
+	}
+
+	return cmd
 }
 
 // Exec formats and runs the commands for building a Docker image.
@@ -701,16 +910,50 @@ func (b *Build) Exec(ctx context.Context) error {
 	// add standardized image labels
 	b.Labels = append(b.Labels, b.AddLabels()...)
 
-	// create the build command for the file
-	cmd := b.Command(ctx)
+	// run the build with the engine
+	return engineOrCLI(b.Engine).Build(ctx, b)
+}
 
-	// run the build command for the file
-	err := execCmd(cmd)
-	if err != nil {
-		return err
+// Buildx returns true when the build must run with a buildx builder.
+func (b *Build) Buildx() bool {
+	return len(b.Platforms) > 0 || len(b.CacheTo) > 0
+}
+
+// secrets returns the secret values for the build arguments flagged
+// with build_args_secret and the secrets forwarded from environment
+// variables or Vela secrets.
+func (b *Build) secrets() []string {
+	// variable to store the secret values
+	var values []string
+
+	// iterate through the build arguments provided or forwarded from environment variables
+	for _, a := range slices.Concat(b.BuildArgs, b.envArgs) {
+		key, value, _ := strings.Cut(a, "=")
+
+		// check if the build argument is flagged as secret
+		if slices.Contains(b.BuildArgsSecret, key) {
+			values = append(values, value)
+		}
+	}
+
+	// iterate through the secrets provided
+	for _, secret := range b.Secrets {
+		// check if the secret is read from an environment variable
+		switch {
+		case len(secret.Env) > 0:
+			values = append(values, os.Getenv(secret.Env))
+		case secret.Type == "env":
+			values = append(values, os.Getenv(secret.ID))
+		case len(secret.Secret) > 0:
+			// a missing value is reported when the secret is written
+			value, err := secret.value()
+			if err == nil {
+				values = append(values, value)
+			}
+		}
 	}
 
-	return nil
+	return values
 }
 
 // AddLabels adds open container spec labels to plugin
@@ -737,19 +980,78 @@ func (b *Build) Unmarshal() error {
 	// allocate structs to store CPU configuration
 	b.CPU = &CPU{}
 
+	// variable to store every problem with the options
+	var errs []error
+
 	// check if any docker options were passed
 	if len(b.CPURaw) > 0 {
-		// cast raw cpu options into bytes
-		cpuOpts := []byte(b.CPURaw)
-
 		// serialize raw cpu options into expected CPU type
-		err := json.Unmarshal(cpuOpts, &b.CPU)
+		err := decodeParam("cpu", "build.cpu", b.CPURaw, b.CPU)
 		if err != nil {
-			return err
+			errs = append(errs, err)
 		}
 	}
 
-	return nil
+	// read the build arguments from the dotenv file
+	//
+	// the build arguments provided directly take precedence over the file
+	args, err := readBuildArgsFile(b.BuildArgsFile)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	b.BuildArgs = append(args, b.BuildArgs...)
+
+	// capture the build arguments forwarded from environment variables
+	b.envArgs, err = envBuildArgs(b.BuildArgsFromEnv)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	// serialize raw build contexts into a map
+	b.BuildContexts, err = parseBuildContexts(b.BuildContextsRaw)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	// serialize raw cache sources into a list
+	b.CacheFrom, err = parseList(b.CacheFromRaw, "type")
+	if err != nil {
+		errs = append(errs, fmt.Errorf("unable to parse cache_from: %w", err))
+	}
+
+	// serialize raw cache destinations into a list
+	b.CacheTo, err = parseList(b.CacheToRaw, "type")
+	if err != nil {
+		errs = append(errs, fmt.Errorf("unable to parse cache_to: %w", err))
+	}
+
+	// serialize raw secrets into expected Secret type
+	b.Secrets, err = parseSecrets(b.SecretsRaw)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	// render the tags provided as templates
+	//
+	// the templates are skipped when validating the tags if they fail to render
+	tags, templates, err := b.Label.renderTags(b.Tags)
+	if err != nil {
+		errs = append(errs, err)
+
+		tags = slices.DeleteFunc(slices.Clone(b.Tags), func(t string) bool {
+			return strings.Contains(t, "{{")
+		})
+	}
+
+	b.Tags, b.templates = tags, templates
+
+	// check if automatic tags are enabled
+	if b.AutoTag {
+		b.Tags = appendTags(b.Tags, b.Label.autoTags()...)
+	}
+
+	return errors.Join(errs...)
 }
 
 // Validate verifies the Build is properly configured.
@@ -761,14 +1063,156 @@ func (b *Build) Validate() error {
 		logrus.Warn("running build in default context")
 	}
 
+	// variable to store every problem with the configuration
+	var errs []error
+
 	// verify tag are provided
 	if len(b.Tags) == 0 {
-		return fmt.Errorf("no build tags provided")
+		errs = append(errs, fmt.Errorf("no build tags provided"))
+	}
+
+	// verify the repository is provided for the automatic tags
+	//
+	// the derived tags (e.g. latest) are not valid image names on their own
+	if b.AutoTag && len(b.Repo) == 0 {
+		errs = append(errs, fmt.Errorf("no repo provided for auto_tag"))
+	}
+
+	// verify a single platform and multiple platforms are not both provided
+	if len(b.Platform) > 0 && len(b.Platforms) > 0 {
+		errs = append(errs, fmt.Errorf("platform and platforms can not be provided together"))
+	}
+
+	// verify the tags have a valid syntax
+	for _, t := range b.Tags {
+		err := validateTag(t)
+		if err == nil {
+			continue
+		}
+
+		// check if the tag was rendered from a template
+		if tmpl, ok := b.templates[t]; ok {
+			err = fmt.Errorf("invalid tag %q rendered from template %q: %w", t, tmpl, err)
+		}
+
+		errs = append(errs, err)
+	}
+
+	// verify the fields with a custom syntax
+	errs = append(errs, b.validateSyntax()...)
+
+	// verify the build contexts exist before the build
+	for _, c := range buildContextFlags(b.BuildContexts) {
+		name, value, _ := strings.Cut(c, "=")
+
+		err := validateBuildContext(name, value)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateSyntax verifies every field with a custom syntax
+// and returns an error for each invalid value.
+func (b *Build) validateSyntax() []error {
+	// variable to store every invalid value
+	var errs []error
+
+	// check adds the error for an invalid value
+	check := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// iterate through the add hosts provided
+	for _, h := range b.AddHosts {
+		check(validateAddHost(h))
+	}
+
+	// iterate through the build args provided
+	for _, a := range b.BuildArgs {
+		check(validateBuildArg(a))
 	}
 
-	//TODO Add validation to fields that have custom syntax
+	// iterate through the build args forwarded from the environment
+	for _, a := range b.BuildArgsFromEnv {
+		check(validateEnvBuildArg(a))
+	}
+
+	// iterate through the build args flagged as secret
+	for _, name := range b.BuildArgsSecret {
+		check(validateSecretBuildArg(name, slices.Concat(b.BuildArgs, b.envArgs)))
+	}
+
+	// iterate through the cache sources provided
+	for _, c := range b.CacheFrom {
+		check(validateCache("cache_from", c, false))
+	}
+
+	// iterate through the cache destinations provided
+	for _, c := range b.CacheTo {
+		check(validateCache("cache_to", c, true))
+	}
+
+	// iterate through the memory arguments provided
+	for _, m := range b.Memory {
+		check(validateBytes("memory", m, false))
+	}
+
+	// iterate through the memory swap arguments provided
+	for _, m := range b.MemorySwaps {
+		check(validateBytes("memory_swaps", m, true))
+	}
+
+	// iterate through the shm sizes provided
+	for _, s := range b.ShmSizes {
+		check(validateBytes("shm_sizes", s, false))
+	}
+
+	// iterate through the ulimits provided
+	for _, u := range b.Ulimits {
+		check(validateUlimit(u))
+	}
+
+	// iterate through the secrets provided
+	for _, secret := range b.Secrets {
+		check(secret.Validate())
+	}
+
+	// iterate through the ssh components provided
+	for _, s := range b.SSHComponents {
+		check(validateSSH(s))
+	}
+
+	// check if Output is provided
+	if len(b.Output) > 0 {
+		check(validateOutput(b.Output))
+	}
+
+	// check if Progress is provided
+	if len(b.Progress) > 0 {
+		check(validateProgress(b.Progress))
+	}
+
+	// check if Network is provided
+	if len(b.Network) > 0 {
+		check(validateNetwork(b.Network))
+	}
+
+	// check if Platform is provided
+	if len(b.Platform) > 0 {
+		check(validatePlatform("platform", b.Platform))
+	}
+
+	// iterate through the platforms provided
+	for _, p := range b.Platforms {
+		check(validatePlatform("platforms", p))
+	}
 
-	return nil
+	return errs
 }
 
 // Flags formats and outputs the flags for
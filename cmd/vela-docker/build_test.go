@@ -15,7 +15,7 @@ func TestDocker_Build_Command(t *testing.T) {
 	b := &Build{
 		AddHosts:     []string{"host.company.com"},
 		BuildArgs:    []string{"FOO=BAR"},
-		CacheFrom:    "index.docker.in/target/vela-docker",
+		CacheFrom:    []string{"index.docker.in/target/vela-docker"},
 		CGroupParent: "parent",
 		Compress:     true,
 		Context:      ".",
@@ -42,7 +42,7 @@ func TestDocker_Build_Command(t *testing.T) {
 		Pull:                true,
 		Quiet:               true,
 		Remove:              true,
-		Secret:              "foo",
+		Secrets:             []*Secret{{ID: "npm", Src: ".npmrc"}, {ID: "pip", Env: "PIP_INDEX_URL"}},
 		SecurityOpts:        []string{"seccomp"},
 		ShmSizes:            []string{"1"},
 		Squash:              true,
@@ -61,7 +61,7 @@ func TestDocker_Build_Command(t *testing.T) {
 		buildAction,
 		fmt.Sprintf("--add-host %s", b.AddHosts[0]),
 		fmt.Sprintf("--build-arg %s", b.BuildArgs[0]),
-		fmt.Sprintf("--cache-from %s", b.CacheFrom),
+		fmt.Sprintf("--cache-from %s", b.CacheFrom[0]),
 		fmt.Sprintf("--cgroup-parent %s", b.CGroupParent),
 		"--compress",
 		fmt.Sprintf("--cpu-period \"%d\"", b.CPU.Period),
@@ -85,7 +85,8 @@ func TestDocker_Build_Command(t *testing.T) {
 		"--pull",
 		"--quiet",
 		"--rm",
-		fmt.Sprintf("--secret %s", b.Secret),
+		"--secret id=npm,src=.npmrc",
+		"--secret id=pip,env=PIP_INDEX_URL",
 		fmt.Sprintf("--security-opt %s", b.SecurityOpts[0]),
 		fmt.Sprintf("--shm-size %s", b.ShmSizes[0]),
 		"--squash",
@@ -103,6 +104,79 @@ func TestDocker_Build_Command(t *testing.T) {
 	}
 }
 
+func TestDocker_Build_Command_Buildx(t *testing.T) {
+	// setup types
+	b := &Build{
+		BuildArgs: []string{"FOO=BAR"},
+		Compress:  true,
+		Context:   ".",
+		CPU: &CPU{
+			Period: 1,
+		},
+		Memory:       []string{"1"},
+		MetadataFile: "/tmp/metadata.json",
+		Platforms:    []string{"linux/amd64", "linux/arm64"},
+		Publish:      true,
+		Remove:       true,
+		Tags:         []string{"index.docker.io/target/vela-docker:latest"},
+	}
+
+	//nolint:gosec // this functionality is not exploitable the way
+	// the plugin accepts configuration
+	want := exec.CommandContext(
+		t.Context(),
+		_docker,
+		buildxAction,
+		buildAction,
+		fmt.Sprintf("--build-arg %s", b.BuildArgs[0]),
+		fmt.Sprintf("--metadata-file %s", b.MetadataFile),
+		"--platform linux/amd64,linux/arm64",
+		"--push",
+		fmt.Sprintf("--tag %s", b.Tags[0]),
+		".",
+	)
+
+	got := b.Command(t.Context())
+	if !strings.EqualFold(got.String(), want.String()) {
+		t.Errorf("Command is %v, want %v", got, want)
+	}
+}
+
+func TestDocker_Build_Command_Cache(t *testing.T) {
+	// setup types
+	b := &Build{
+		CacheFrom: []string{"type=registry,ref=ghcr.io/octocat/hello-world:cache", "type=local,src=/tmp/cache"},
+		CacheTo:   []string{"type=registry,ref=ghcr.io/octocat/hello-world:cache,mode=max"},
+		Context:   ".",
+		CPU:       &CPU{},
+		Tags:      []string{"ghcr.io/octocat/hello-world:latest"},
+	}
+
+	if !b.Buildx() {
+		t.Errorf("Buildx should be enabled when cache_to is provided")
+	}
+
+	//nolint:gosec // this functionality is not exploitable the way
+	// the plugin accepts configuration
+	want := exec.CommandContext(
+		t.Context(),
+		_docker,
+		buildxAction,
+		buildAction,
+		fmt.Sprintf("--cache-from %s", b.CacheFrom[0]),
+		fmt.Sprintf("--cache-from %s", b.CacheFrom[1]),
+		fmt.Sprintf("--cache-to %s", b.CacheTo[0]),
+		"--load",
+		fmt.Sprintf("--tag %s", b.Tags[0]),
+		".",
+	)
+
+	got := b.Command(t.Context())
+	if !strings.EqualFold(got.String(), want.String()) {
+		t.Errorf("Command is %v, want %v", got, want)
+	}
+}
+
 func TestDocker_Build_Exec_Error(t *testing.T) {
 	// setup types
 	b := &Build{
@@ -116,6 +190,94 @@ func TestDocker_Build_Exec_Error(t *testing.T) {
 	}
 }
 
+func TestDocker_Build_Unmarshal_AutoTag(t *testing.T) {
+	// setup types
+	b := &Build{
+		AutoTag: true,
+		Label: &Label{
+			Commit: "48afb5bdc41ad69bf22588491333f7cf71135163",
+			Event:  "tag",
+			Tag:    "v1.2.3",
+		},
+		Tags: []string{"stable", "1.2.3"},
+	}
+
+	want := []string{"stable", "1.2.3", "48afb5b", "1", "1.2"}
+
+	err := b.Unmarshal()
+	if err != nil {
+		t.Errorf("Unmarshal returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(b.Tags, want) {
+		t.Errorf("Unmarshal tags are %v, want %v", b.Tags, want)
+	}
+}
+
+func TestDocker_Build_Validate_AutoTag(t *testing.T) {
+	// setup types
+	b := &Build{
+		AutoTag: true,
+		Context: ".",
+		Label: &Label{
+			Branch: "main",
+			Commit: "48afb5bdc41ad69bf22588491333f7cf71135163",
+			Event:  "push",
+		},
+	}
+
+	err := b.Unmarshal()
+	if err != nil {
+		t.Errorf("Unmarshal returned err: %v", err)
+	}
+
+	// verify the derived tags are not published without a repository
+	err = b.Validate()
+	if err == nil || !strings.Contains(err.Error(), "no repo provided for auto_tag") {
+		t.Errorf("Validate should have returned err for the missing repo, got %v", err)
+	}
+
+	b.Repo = "octocat/hello-world"
+
+	err = b.Validate()
+	if err != nil {
+		t.Errorf("Validate returned err: %v", err)
+	}
+}
+
+func TestDocker_Build_Validate_TagTemplate(t *testing.T) {
+	// setup types
+	b := &Build{
+		Label: &Label{
+			Branch: "feature/foo bar",
+		},
+		Tags: []string{"{{ .Branch }}"},
+	}
+
+	err := b.Unmarshal()
+	if err != nil {
+		t.Errorf("Unmarshal returned err: %v", err)
+	}
+
+	err = b.Validate()
+	if err == nil || !strings.Contains(err.Error(), "{{ .Branch }}") {
+		t.Errorf("Validate should have returned err naming the template, got %v", err)
+	}
+
+	// sanitize the branch into a valid tag
+	b.Tags = []string{"{{ .Branch | sanitize }}"}
+
+	err = b.Unmarshal()
+	if err != nil {
+		t.Errorf("Unmarshal returned err: %v", err)
+	}
+
+	err = b.Validate()
+	if err != nil {
+		t.Errorf("Validate returned err: %v", err)
+	}
+}
+
 func TestDocker_Build_Unmarshal(t *testing.T) {
 	// setup types
 	b := &Build{
@@ -180,3 +342,18 @@ func TestDocker_Build_Validate_NoTag(t *testing.T) {
 		t.Errorf("Validate should have returned err")
 	}
 }
+
+func TestDocker_Build_Validate_PlatformConflict(t *testing.T) {
+	// setup types
+	b := &Build{
+		Context:   ".",
+		Platform:  "linux/amd64",
+		Platforms: []string{"linux/amd64", "linux/arm64"},
+		Tags:      []string{"latest"},
+	}
+
+	err := b.Validate()
+	if err == nil {
+		t.Errorf("Validate should have returned err")
+	}
+}
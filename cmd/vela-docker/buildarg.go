@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+// readBuildArgsFile captures the build arguments from a file in
+// the dotenv format as KEY=VALUE pairs in a consistent order.
+func readBuildArgsFile(path string) ([]string, error) {
+	// check if a file is provided
+	if len(path) == 0 {
+		return nil, nil
+	}
+
+	// use custom filesystem which enables us to test
+	f, err := appFS.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open build_args_file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	vars, err := godotenv.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse build_args_file %s: %w", path, err)
+	}
+
+	// variable to store the names of the build arguments
+	keys := make([]string, 0, len(vars))
+
+	for key := range vars {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	// variable to store the captured build arguments
+	args := make([]string, 0, len(keys))
+
+	for _, key := range keys {
+		args = append(args, fmt.Sprintf("%s=%s", key, vars[key]))
+	}
+
+	return args, nil
+}
+
+// envBuildArgs captures the build arguments forwarded from environment
+// variables in the format NAME or ARG=NAME as ARG=VALUE pairs.
+//
+// The values are provided to the docker CLI through its environment
+// so they are never part of the command echoed to the build output.
+func envBuildArgs(names []string) ([]string, error) {
+	// variable to store the captured build arguments
+	args := make([]string, 0, len(names))
+
+	for _, entry := range names {
+		arg, name, ok := strings.Cut(entry, "=")
+		if !ok {
+			name = arg
+		}
+
+		// verify the build argument and environment variable are valid
+		if len(arg) == 0 || len(name) == 0 || strings.ContainsAny(entry, " \t\n") {
+			return nil, invalidParameter("build_args_from_env", entry, "must be in the format NAME or ARG=NAME")
+		}
+
+		// variable to store if the environment variable was found
+		found := false
+
+		// iterate through the environment variables the value may be injected as
+		for _, n := range []string{name, strings.ToUpper(name)} {
+			if value, ok := os.LookupEnv(n); ok {
+				args = append(args, fmt.Sprintf("%s=%s", arg, value))
+				found = true
+
+				break
+			}
+		}
+
+		// verify the environment variable was provided to the step
+		if !found {
+			return nil, invalidParameter("build_args_from_env", entry, fmt.Sprintf("environment variable %s is not set", name))
+		}
+	}
+
+	return args, nil
+}
@@ -0,0 +1,171 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestDocker_readBuildArgsFile(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	err := afero.WriteFile(appFS, "/vela/src/build.env", []byte("# versions\nGO_VERSION=1.23\nexport NODE_VERSION=\"20\"\nAPP=hello-${GO_VERSION}\n"), 0644)
+	if err != nil {
+		t.Fatalf("WriteFile returned err: %v", err)
+	}
+
+	want := []string{"APP=hello-1.23", "GO_VERSION=1.23", "NODE_VERSION=20"}
+
+	got, err := readBuildArgsFile("/vela/src/build.env")
+	if err != nil {
+		t.Errorf("readBuildArgsFile returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readBuildArgsFile is %v, want %v", got, want)
+	}
+
+	_, err = readBuildArgsFile("/vela/src/missing.env")
+	if err == nil {
+		t.Errorf("readBuildArgsFile should have returned err")
+	}
+}
+
+func TestDocker_envBuildArgs(t *testing.T) {
+	// setup environment
+	t.Setenv("NPM_TOKEN", "superSecretToken")
+
+	// setup tests
+	tests := []struct {
+		failure bool
+		names   []string
+		want    []string
+	}{
+		{
+			failure: false,
+			names:   []string{"NPM_TOKEN", "TOKEN=NPM_TOKEN", "AUTH=npm_token"},
+			want:    []string{"NPM_TOKEN=superSecretToken", "TOKEN=superSecretToken", "AUTH=superSecretToken"},
+		},
+		{
+			failure: true,
+			names:   []string{"VELA_DOCKER_MISSING_TOKEN"},
+		},
+		{
+			failure: true,
+			names:   []string{"TOKEN="},
+		},
+		{
+			failure: true,
+			names:   []string{"NPM TOKEN"},
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got, err := envBuildArgs(test.names)
+
+		if test.failure {
+			if err == nil {
+				t.Errorf("envBuildArgs should have returned err for %v", test.names)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("envBuildArgs returned err: %v", err)
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("envBuildArgs is %v, want %v", got, test.want)
+		}
+	}
+}
+
+func TestDocker_Build_Unmarshal_BuildArgs(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	err := afero.WriteFile(appFS, "build.env", []byte("GO_VERSION=1.22\n"), 0644)
+	if err != nil {
+		t.Fatalf("WriteFile returned err: %v", err)
+	}
+
+	// setup environment
+	t.Setenv("NPM_TOKEN", "superSecretToken")
+
+	// setup types
+	b := &Build{
+		BuildArgs:        []string{"GO_VERSION=1.23"},
+		BuildArgsFile:    "build.env",
+		BuildArgsFromEnv: []string{"NPM_TOKEN"},
+		Context:          ".",
+		Label:            &Label{},
+	}
+
+	err = b.Unmarshal()
+	if err != nil {
+		t.Fatalf("Unmarshal returned err: %v", err)
+	}
+
+	// the build arguments provided directly are last so they take precedence
+	want := []string{"GO_VERSION=1.22", "GO_VERSION=1.23"}
+
+	if !reflect.DeepEqual(b.BuildArgs, want) {
+		t.Errorf("Unmarshal build args are %v, want %v", b.BuildArgs, want)
+	}
+
+	cmd := b.Command(t.Context())
+
+	// verify the value is not part of the echoed command
+	if strings.Contains(cmd.String(), "superSecretToken") {
+		t.Errorf("Command should not contain the value of NPM_TOKEN: %s", cmd)
+	}
+
+	if !strings.Contains(cmd.String(), "--build-arg NPM_TOKEN ") {
+		t.Errorf("Command should forward NPM_TOKEN: %s", cmd)
+	}
+
+	if !slices.Contains(cmd.Env, "NPM_TOKEN=superSecretToken") {
+		t.Errorf("Command environment should contain NPM_TOKEN")
+	}
+}
+
+func TestDocker_Build_secrets_BuildArgs(t *testing.T) {
+	// setup environment
+	t.Setenv("NPM_TOKEN", "superSecretToken")
+	t.Setenv("RETRIES", "42")
+
+	// setup types
+	b := &Build{
+		BuildArgs:        []string{"GO_VERSION=1.23", "API_KEY=superSecretKey", "DEBUG=true"},
+		BuildArgsFromEnv: []string{"NPM_TOKEN", "RETRIES"},
+		BuildArgsSecret:  []string{"API_KEY", "NPM_TOKEN"},
+		Context:          ".",
+		Label:            &Label{},
+		Tags:             []string{"octocat/hello-world:latest"},
+	}
+
+	err := b.Unmarshal()
+	if err != nil {
+		t.Fatalf("Unmarshal returned err: %v", err)
+	}
+
+	err = b.Validate()
+	if err != nil {
+		t.Errorf("Validate returned err: %v", err)
+	}
+
+	// only the values of the build arguments flagged as secret are redacted
+	want := []string{"superSecretKey", "superSecretToken"}
+
+	if got := b.secrets(); !reflect.DeepEqual(got, want) {
+		t.Errorf("secrets is %v, want %v", got, want)
+	}
+}
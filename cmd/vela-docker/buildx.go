@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os/exec"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// buildxAction is the docker plugin used for BuildKit builds.
+	buildxAction = "buildx"
+
+	// buildxBuilder is the prefix for the name of the builder created inside the daemon.
+	buildxBuilder = "vela"
+
+	// buildxDriver is the driver used for the builder created inside the daemon.
+	//
+	// the docker-container driver is required for producing
+	// multi-platform images and manifest lists.
+	buildxDriver = "docker-container"
+)
+
+// buildxPushErrors represents the errors output by buildx when
+// exporting or pushing the image to the registry fails.
+var buildxPushErrors = []string{
+	"failed to push",
+	"failed to export",
+	"error writing layer blob",
+}
+
+// buildxPushError returns the lines of the buildx output reporting a
+// failure to export or push the image or an empty string.
+//
+// The lines are used for classifying the error so a failure of the
+// build itself (e.g. a RUN step unable to reach a service) is not retried.
+func buildxPushError(out string) string {
+	// variable to store the lines reporting a push failure
+	var lines []string
+
+	for _, line := range strings.Split(out, "\n") {
+		for _, e := range buildxPushErrors {
+			if strings.Contains(strings.ToLower(line), e) {
+				lines = append(lines, line)
+
+				break
+			}
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// buildxBuilderName returns a unique name for the builder created
+// inside the daemon so builds sharing an external daemon do not
+// use or remove the builder of another step.
+func buildxBuilderName() string {
+	suffix := make([]byte, 6)
+
+	// the random bytes are always filled
+	_, _ = rand.Read(suffix)
+
+	return buildxBuilder + "-" + hex.EncodeToString(suffix)
+}
+
+// buildxCreateCmd is a helper function to create
+// a BuildKit builder inside the daemon.
+func buildxCreateCmd(ctx context.Context, name string) *exec.Cmd {
+	logrus.Trace("creating docker buildx create command")
+
+	// variable to store flags for command
+	var flags []string
+
+	// add flags for the builder name and driver
+	flags = append(flags, "create", "--name", name, "--driver", buildxDriver)
+
+	// add flag to set the builder as the default
+	flags = append(flags, "--use")
+
+	return exec.CommandContext(ctx, _docker, append([]string{buildxAction}, flags...)...)
+}
+
+// buildxInspectCmd is a helper function to bootstrap
+// the BuildKit builder and output the supported platforms.
+func buildxInspectCmd(ctx context.Context, name string) *exec.Cmd {
+	logrus.Trace("creating docker buildx inspect command")
+
+	// variable to store flags for command
+	var flags []string
+
+	// add flags for bootstrapping the builder
+	flags = append(flags, "inspect", "--bootstrap", name)
+
+	return exec.CommandContext(ctx, _docker, append([]string{buildxAction}, flags...)...)
+}
+
+// buildxRmCmd is a helper function to remove
+// the BuildKit builder from the daemon.
+func buildxRmCmd(ctx context.Context, name string) *exec.Cmd {
+	logrus.Trace("creating docker buildx rm command")
+
+	// variable to store flags for command
+	var flags []string
+
+	// add flags for removing the builder
+	flags = append(flags, "rm", name)
+
+	return exec.CommandContext(ctx, _docker, append([]string{buildxAction}, flags...)...)
+}
@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"os/exec"
+	"regexp"
+	"testing"
+)
+
+func TestDocker_buildxCreateCmd(t *testing.T) {
+	// setup types
+	want := exec.CommandContext(
+		t.Context(),
+		_docker,
+		buildxAction,
+		"create",
+		"--name", "vela-0a1b2c",
+		"--driver", buildxDriver,
+		"--use",
+	)
+
+	got := buildxCreateCmd(t.Context(), "vela-0a1b2c")
+
+	if got.String() != want.String() {
+		t.Errorf("buildxCreateCmd is %v, want %v", got, want)
+	}
+}
+
+func TestDocker_buildxInspectCmd(t *testing.T) {
+	// setup types
+	want := exec.CommandContext(
+		t.Context(),
+		_docker,
+		buildxAction,
+		"inspect",
+		"--bootstrap",
+		"vela-0a1b2c",
+	)
+
+	got := buildxInspectCmd(t.Context(), "vela-0a1b2c")
+
+	if got.String() != want.String() {
+		t.Errorf("buildxInspectCmd is %v, want %v", got, want)
+	}
+}
+
+func TestDocker_buildxRmCmd(t *testing.T) {
+	// setup types
+	want := exec.CommandContext(
+		t.Context(),
+		_docker,
+		buildxAction,
+		"rm",
+		"vela-0a1b2c",
+	)
+
+	got := buildxRmCmd(t.Context(), "vela-0a1b2c")
+
+	if got.String() != want.String() {
+		t.Errorf("buildxRmCmd is %v, want %v", got, want)
+	}
+}
+
+func TestDocker_buildxBuilderName(t *testing.T) {
+	got := buildxBuilderName()
+
+	if !regexp.MustCompile(`^vela-[0-9a-f]{12}$`).MatchString(got) {
+		t.Errorf("buildxBuilderName is %s, want vela-<id>", got)
+	}
+
+	// verify each step uses a different builder
+	if got == buildxBuilderName() {
+		t.Errorf("buildxBuilderName should be unique, got %s twice", got)
+	}
+}
@@ -0,0 +1,166 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// cacheTypes represents the valid BuildKit cache backends.
+var cacheTypes = []string{"inline", "local", "registry"}
+
+// validateCache verifies the cache is an image reference or in the format
+// type=<type>[,key=value] for the provided parameter.
+//
+// The export parameter signifies the cache is exported with cache_to.
+func validateCache(name, s string, export bool) error {
+	// check if the shorthand for a registry cache is provided
+	if !strings.Contains(s, "=") {
+		_, err := ParseReference(s)
+		if err != nil {
+			return invalidParameter(name, s, err.Error())
+		}
+
+		return nil
+	}
+
+	// variable to store the provided fields
+	fields := make(map[string]string)
+
+	for _, field := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return invalidParameter(name, s, fmt.Sprintf("invalid field %q - must be in the format key=value", field))
+		}
+
+		fields[key] = value
+	}
+
+	// verify the type is valid
+	if !slices.Contains(cacheTypes, fields["type"]) {
+		return invalidParameter(name, s, fmt.Sprintf("invalid type %q - options (%s)", fields["type"], strings.Join(cacheTypes, "|")))
+	}
+
+	// verify the mode is valid
+	if mode, ok := fields["mode"]; ok && mode != "min" && mode != "max" {
+		return invalidParameter(name, s, fmt.Sprintf("invalid mode %q - options (min|max)", mode))
+	}
+
+	switch fields["type"] {
+	case "inline":
+		// verify the inline cache is only exported
+		if !export {
+			return invalidParameter(name, s, "inline cache is imported with type=registry or an image reference")
+		}
+	case "local":
+		// variable to store the directory field of the local cache
+		dir := "src"
+		if export {
+			dir = "dest"
+		}
+
+		// verify the directory is provided
+		if len(fields[dir]) == 0 {
+			return invalidParameter(name, s, fmt.Sprintf("no %s provided for local cache", dir))
+		}
+	case "registry":
+		// verify the reference is provided
+		if len(fields["ref"]) == 0 {
+			return invalidParameter(name, s, "no ref provided for registry cache")
+		}
+
+		_, err := ParseReference(fields["ref"])
+		if err != nil {
+			return invalidParameter(name, s, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// resolveCaches normalizes the references of every registry cache against the
+// registry so caches are pushed to and pulled from the authenticated registry.
+func resolveCaches(caches []string, registry string) ([]string, error) {
+	// variable to store the resolved caches
+	resolved := make([]string, 0, len(caches))
+
+	for _, c := range caches {
+		// check if the shorthand for a registry cache is provided
+		if !strings.Contains(c, "=") {
+			r, err := resolveTag(c, registry, "")
+			if err != nil {
+				return nil, err
+			}
+
+			resolved = append(resolved, r.String())
+
+			continue
+		}
+
+		fields := strings.Split(c, ",")
+
+		// check if the cache is a registry cache
+		if !slices.Contains(fields, "type=registry") {
+			resolved = append(resolved, c)
+
+			continue
+		}
+
+		for i, field := range fields {
+			// check if the field is the reference
+			ref, ok := strings.CutPrefix(field, "ref=")
+			if !ok {
+				continue
+			}
+
+			r, err := resolveTag(ref, registry, "")
+			if err != nil {
+				return nil, err
+			}
+
+			fields[i] = "ref=" + r.String()
+		}
+
+		resolved = append(resolved, strings.Join(fields, ","))
+	}
+
+	return resolved, nil
+}
+
+// cacheHosts returns the registry host of every registry cache.
+func cacheHosts(caches []string) []string {
+	// variable to store the registry hosts
+	var hosts []string
+
+	for _, c := range caches {
+		ref := c
+
+		// check if the cache is not the shorthand for a registry cache
+		if strings.Contains(c, "=") {
+			ref = ""
+
+			for _, field := range strings.Split(c, ",") {
+				if r, ok := strings.CutPrefix(field, "ref="); ok {
+					ref = r
+				}
+			}
+		}
+
+		r, err := ParseReference(ref)
+		if err != nil {
+			continue
+		}
+
+		// default to Docker Hub when no registry is provided
+		host := r.Registry
+		if len(host) == 0 {
+			host = "index.docker.io"
+		}
+
+		hosts = append(hosts, host)
+	}
+
+	return hosts
+}
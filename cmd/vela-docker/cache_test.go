@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDocker_parseList(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		failure bool
+		raw     string
+		want    []string
+	}{
+		{
+			failure: false,
+			raw:     "index.docker.io/octocat/hello-world",
+			want:    []string{"index.docker.io/octocat/hello-world"},
+		},
+		{
+			// list of strings provided as a comma-separated value
+			failure: false,
+			raw:     "octocat/hello-world:cache,type=registry,ref=octocat/hello-world:buildcache,mode=max,type=inline",
+			want:    []string{"octocat/hello-world:cache", "type=registry,ref=octocat/hello-world:buildcache,mode=max", "type=inline"},
+		},
+		{
+			failure: false,
+			raw:     `["type=local,dest=/tmp/cache", "type=inline"]`,
+			want:    []string{"type=local,dest=/tmp/cache", "type=inline"},
+		},
+		{
+			failure: false,
+			raw:     " ",
+		},
+		{
+			failure: true,
+			raw:     `[{"type": "inline"}]`,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got, err := parseList(test.raw, "type")
+
+		if test.failure {
+			if err == nil {
+				t.Errorf("parseList should have returned err for %s", test.raw)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("parseList returned err: %v", err)
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseList is %v, want %v", got, test.want)
+		}
+	}
+}
+
+func TestDocker_validateCache(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		failure bool
+		export  bool
+		cache   string
+	}{
+		{failure: false, export: false, cache: "octocat/hello-world:cache"},
+		{failure: false, export: false, cache: "type=registry,ref=ghcr.io/octocat/hello-world:cache"},
+		{failure: false, export: false, cache: "type=local,src=/tmp/cache"},
+		{failure: false, export: true, cache: "type=registry,ref=octocat/hello-world:cache,mode=max"},
+		{failure: false, export: true, cache: "type=local,dest=/tmp/cache,mode=min"},
+		{failure: false, export: true, cache: "type=inline"},
+		{failure: true, export: false, cache: "type=inline"},
+		{failure: true, export: false, cache: "type=local,dest=/tmp/cache"},
+		{failure: true, export: true, cache: "type=local,src=/tmp/cache"},
+		{failure: true, export: true, cache: "type=registry"},
+		{failure: true, export: true, cache: "type=registry,ref=octocat/hello-world:cache,mode=all"},
+		{failure: true, export: true, cache: "type=foo"},
+		{failure: true, export: true, cache: "ref=octocat/hello-world:cache"},
+		{failure: true, export: true, cache: "type=inline,foo"},
+		{failure: true, export: false, cache: "Octocat/Hello World"},
+	}
+
+	// run tests
+	for _, test := range tests {
+		err := validateCache("cache_to", test.cache, test.export)
+
+		if test.failure {
+			if err == nil {
+				t.Errorf("validateCache should have returned err for %s", test.cache)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("validateCache returned err: %v", err)
+		}
+	}
+}
+
+func TestDocker_resolveCaches(t *testing.T) {
+	// setup types
+	caches := []string{
+		"octocat/hello-world:cache",
+		"type=registry,ref=octocat/hello-world:cache,mode=max",
+		"type=registry,ref=index.docker.io/octocat/hello-world:cache",
+		"type=inline",
+	}
+
+	want := []string{
+		"ghcr.io/octocat/hello-world:cache",
+		"type=registry,ref=ghcr.io/octocat/hello-world:cache,mode=max",
+		"type=registry,ref=index.docker.io/octocat/hello-world:cache",
+		"type=inline",
+	}
+
+	got, err := resolveCaches(caches, "ghcr.io")
+	if err != nil {
+		t.Errorf("resolveCaches returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("resolveCaches is %v, want %v", got, want)
+	}
+
+	hosts := cacheHosts(got)
+	wantHosts := []string{"ghcr.io", "ghcr.io", "index.docker.io"}
+
+	if !reflect.DeepEqual(hosts, wantHosts) {
+		t.Errorf("cacheHosts is %v, want %v", hosts, wantHosts)
+	}
+}
+
+func TestDocker_Registry_authenticates(t *testing.T) {
+	// setup types
+	r := &Registry{
+		Name:       "index.docker.io",
+		Registries: []*Registry{{Name: "https://ghcr.io"}},
+	}
+
+	for _, host := range []string{"index.docker.io", "docker.io", "ghcr.io"} {
+		if !r.authenticates(host) {
+			t.Errorf("authenticates should be true for %s", host)
+		}
+	}
+
+	if r.authenticates("quay.io") {
+		t.Errorf("authenticates should be false for quay.io")
+	}
+}
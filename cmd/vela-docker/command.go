@@ -5,34 +5,89 @@ package main
 import (
 	"context"
 	"fmt"
-	"os"
+	"io"
 	"os/exec"
 	"strings"
 
 	"github.com/sirupsen/logrus"
 )
 
-const (
-	// _docker is the path to the executable binary in the image.
-	_docker = "/usr/local/bin/docker"
-	// _dockerd is the path to the executable daemon in the image.
-	_dockerd = "/usr/local/bin/dockerd"
-)
+// _dockerd is the path to the executable daemon in the image.
+const _dockerd = "/usr/local/bin/dockerd"
+
+// _dockerdRootless is the path to the script starting the
+// daemon without root privileges in the image.
+//
+// This is a variable to enable replacing the script in tests.
+var _dockerdRootless = "/usr/local/bin/dockerd-rootless.sh"
+
+// _docker is the path to the executable binary in the image.
+//
+// This is a variable to enable replacing the binary in tests.
+var _docker = "/usr/local/bin/docker"
+
+// unredacted represents the output of a command which is written
+// redacted to the writer and captured before redaction by raw.
+//
+// This enables parsing values from the output (e.g. the digest of
+// a pushed image) which may contain a secret value by chance.
+type unredacted struct {
+	io.Writer
+	// captures the output before the secret values are redacted
+	raw io.Writer
+}
+
+// redactOutput returns the writer for the output of a command and the
+// writer redacting the output which must be flushed once it is complete.
+func redactOutput(w io.Writer) (io.Writer, *redactWriter) {
+	// check if the output is captured before redaction
+	if u, ok := w.(*unredacted); ok {
+		out := secretMask.writer(u.Writer)
+
+		return io.MultiWriter(u.raw, out), out
+	}
+
+	out := secretMask.writer(w)
+
+	return out, out
+}
 
 // execCmd is a helper function to
-// run the provided command.
-func execCmd(e *exec.Cmd) error {
+// run the provided command with the runner.
+func execCmd(r Runner, e *exec.Cmd) error {
 	logrus.Tracef("executing cmd %s", strings.Join(e.Args, " "))
 
-	// set command stdout to OS stdout
-	e.Stdout = os.Stdout
-	// set command stderr to OS stderr
-	e.Stderr = os.Stderr
+	// default to running the command on the host
+	r = runnerOrExec(r)
+
+	// check if the command stdout is already captured
+	if e.Stdout == nil {
+		// set command stdout to the runner stdout
+		e.Stdout = r.Stdout()
+	}
+
+	// check if the command stderr is already captured
+	if e.Stderr == nil {
+		// set command stderr to the runner stderr
+		e.Stderr = r.Stderr()
+	}
+
+	// redact the secret values from the command output
+	var stdout, stderr *redactWriter
+
+	e.Stdout, stdout = redactOutput(e.Stdout)
+	e.Stderr, stderr = redactOutput(e.Stderr)
 
 	// output "trace" string for command
-	fmt.Println("$", strings.Join(e.Args, " "))
+	fmt.Fprintln(stdout, "$", strings.Join(e.Args, " "))
 
-	return e.Run()
+	err := r.Run(e)
+
+	// write the remaining output of the command
+	_ = stdout.Flush()
+	_ = stderr.Flush()
+
+	return err
 }
 
 // infoCmd is a helper function to check if
@@ -62,3 +117,17 @@ func versionCmd(ctx context.Context) *exec.Cmd {
 
 	return exec.CommandContext(ctx, _docker, flags...)
 }
+
+// inspectCmd is a helper function to output
+// the details of the image in JSON.
+func inspectCmd(ctx context.Context, ref string) *exec.Cmd {
+	logrus.Trace("creating docker image inspect command")
+
+	// variable to store flags for command
+	var flags []string
+
+	// add flags for inspecting the image in JSON
+	flags = append(flags, "image", "inspect", "--format", "{{json .}}", ref)
+
+	return exec.CommandContext(ctx, _docker, flags...)
+}
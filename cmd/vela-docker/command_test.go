@@ -11,7 +11,7 @@ func TestDocker_execCmd(t *testing.T) {
 	// setup types
 	e := exec.CommandContext(t.Context(), "echo", "hello")
 
-	err := execCmd(e)
+	err := execCmd(nil, e)
 	if err != nil {
 		t.Errorf("execCmd returned err: %v", err)
 	}
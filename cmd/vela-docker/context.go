@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	// dockerImagePrefix is the prefix for a build context from an image.
+	dockerImagePrefix = "docker-image://"
+	// ociLayoutPrefix is the prefix for a build context from an OCI layout directory.
+	ociLayoutPrefix = "oci-layout://"
+)
+
+// gitPrefixes represents the prefixes of a build context from a Git repository or a URL.
+var gitPrefixes = []string{"git://", "git@", "http://", "https://", "ssh://"}
+
+// parseBuildContexts captures the named build contexts from a
+// JSON object or a comma-separated list of name=value pairs.
+func parseBuildContexts(raw string) (map[string]string, error) {
+	raw = strings.TrimSpace(raw)
+
+	// check if any build contexts are provided
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	// variable to store the captured build contexts
+	contexts := make(map[string]string)
+
+	// check if the build contexts are not provided as a JSON object
+	if !strings.HasPrefix(raw, "{") {
+		for _, pair := range strings.Split(raw, ",") {
+			name, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				return nil, invalidParameter("build_contexts", pair, "must be in the format name=value")
+			}
+
+			contexts[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+
+		return contexts, nil
+	}
+
+	err := json.Unmarshal([]byte(raw), &contexts)
+	if err != nil {
+		return nil, fmt.Errorf("unable to unmarshal build_contexts: %w", err)
+	}
+
+	return contexts, nil
+}
+
+// buildContextFlags formats the named build contexts for the
+// --build-context flag in a consistent order.
+func buildContextFlags(contexts map[string]string) []string {
+	// variable to store the names of the build contexts
+	names := make([]string, 0, len(contexts))
+
+	for name := range contexts {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	// variable to store the formatted build contexts
+	flags := make([]string, 0, len(names))
+
+	for _, name := range names {
+		flags = append(flags, fmt.Sprintf("%s=%s", name, contexts[name]))
+	}
+
+	return flags
+}
+
+// validateBuildContext verifies the build context is a local directory,
+// an image in the format docker-image://<image>, an OCI layout directory
+// or a Git repository URL.
+func validateBuildContext(name, value string) error {
+	// variable to store the build context for error messages
+	context := fmt.Sprintf("%s=%s", name, value)
+
+	// verify the name is provided
+	if len(name) == 0 || strings.ContainsAny(name, " \t") {
+		return invalidParameter("build_contexts", context, fmt.Sprintf("invalid name %q", name))
+	}
+
+	// verify the value is provided
+	if len(value) == 0 {
+		return invalidParameter("build_contexts", context, "no value provided")
+	}
+
+	// check if the build context is an image
+	if ref, ok := strings.CutPrefix(value, dockerImagePrefix); ok {
+		_, err := ParseReference(ref)
+		if err != nil {
+			return invalidParameter("build_contexts", context, err.Error())
+		}
+
+		return nil
+	}
+
+	// check if the build context is a Git repository or URL
+	for _, prefix := range gitPrefixes {
+		if strings.HasPrefix(value, prefix) {
+			return nil
+		}
+	}
+
+	// check if the build context is an OCI layout
+	path, layout := strings.CutPrefix(value, ociLayoutPrefix)
+	if layout {
+		// remove the digest from the OCI layout
+		path, _, _ = strings.Cut(path, "@")
+
+		// remove the tag from the OCI layout
+		if i := strings.LastIndex(path, ":"); i > strings.LastIndex(path, "/") {
+			path = path[:i]
+		}
+	}
+
+	// verify the local directory exists before the build
+	info, err := appFS.Stat(path)
+	if err != nil {
+		return invalidParameter("build_contexts", context, fmt.Sprintf("unable to find local path %q", path))
+	}
+
+	if !info.IsDir() {
+		return invalidParameter("build_contexts", context, fmt.Sprintf("local path %q is not a directory", path))
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestDocker_parseBuildContexts(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		failure bool
+		raw     string
+		want    map[string]string
+	}{
+		{
+			failure: false,
+			raw:     `{"shared": "../shared", "alpine": "docker-image://alpine:3.20"}`,
+			want:    map[string]string{"shared": "../shared", "alpine": "docker-image://alpine:3.20"},
+		},
+		{
+			failure: false,
+			raw:     "shared=../shared,docs=https://github.com/octocat/docs.git",
+			want:    map[string]string{"shared": "../shared", "docs": "https://github.com/octocat/docs.git"},
+		},
+		{
+			failure: false,
+			raw:     "",
+		},
+		{
+			failure: true,
+			raw:     "shared",
+		},
+		{
+			failure: true,
+			raw:     `{"shared": 1}`,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got, err := parseBuildContexts(test.raw)
+
+		if test.failure {
+			if err == nil {
+				t.Errorf("parseBuildContexts should have returned err for %s", test.raw)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("parseBuildContexts returned err: %v", err)
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseBuildContexts is %v, want %v", got, test.want)
+		}
+	}
+}
+
+func TestDocker_validateBuildContext(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	_ = appFS.MkdirAll("/vela/src/shared", 0755)
+	_ = appFS.MkdirAll("/vela/src/layout", 0755)
+	_ = afero.WriteFile(appFS, "/vela/src/Dockerfile", []byte("FROM alpine"), 0644)
+
+	// setup tests
+	tests := []struct {
+		failure bool
+		name    string
+		value   string
+	}{
+		{failure: false, name: "shared", value: "/vela/src/shared"},
+		{failure: false, name: "alpine", value: "docker-image://alpine:3.20"},
+		{failure: false, name: "docker.io/library/alpine:3.20", value: "docker-image://alpine:3.21"},
+		{failure: false, name: "docs", value: "https://github.com/octocat/docs.git#main"},
+		{failure: false, name: "docs", value: "git@github.com:octocat/docs.git"},
+		{failure: false, name: "base", value: "oci-layout:///vela/src/layout:latest"},
+		{failure: false, name: "base", value: "oci-layout:///vela/src/layout@sha256:" + "a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2"},
+		{failure: true, name: "missing", value: "/vela/src/missing"},
+		{failure: true, name: "file", value: "/vela/src/Dockerfile"},
+		{failure: true, name: "alpine", value: "docker-image://Alpine Linux"},
+		{failure: true, name: "", value: "/vela/src/shared"},
+		{failure: true, name: "shared", value: ""},
+	}
+
+	// run tests
+	for _, test := range tests {
+		err := validateBuildContext(test.name, test.value)
+
+		if test.failure {
+			if err == nil {
+				t.Errorf("validateBuildContext should have returned err for %s=%s", test.name, test.value)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("validateBuildContext returned err: %v", err)
+		}
+	}
+}
+
+func TestDocker_Build_Command_BuildContexts(t *testing.T) {
+	// setup types
+	b := &Build{
+		BuildContexts: map[string]string{
+			"shared": "../shared",
+			"alpine": "docker-image://alpine:3.20",
+		},
+		Context: ".",
+		CPU:     &CPU{},
+		Tags:    []string{"octocat/hello-world:latest"},
+	}
+
+	want := []string{
+		"--build-context", "alpine=docker-image://alpine:3.20",
+		"--build-context", "shared=../shared",
+	}
+
+	got := b.Command(t.Context()).Args
+
+	if !reflect.DeepEqual(got[2:6], want) {
+		t.Errorf("Command args are %v, want %v", got[2:6], want)
+	}
+}
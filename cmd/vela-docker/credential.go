@@ -0,0 +1,651 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	// ecrHelper is the credential helper for AWS Elastic Container Registry.
+	ecrHelper = "ecr"
+	// gcrHelper is the credential helper for Google Container Registry and Artifact Registry.
+	gcrHelper = "gcr"
+	// acrHelper is the credential helper for Azure Container Registry.
+	acrHelper = "acr"
+
+	// ecrTarget is the API operation for retrieving an ECR authorization token.
+	ecrTarget = "AmazonEC2ContainerRegistry_V20150921.GetAuthorizationToken"
+	// gcrScope is the OAuth scope requested for the GCP access token.
+	gcrScope = "https://www.googleapis.com/auth/cloud-platform"
+	// gcrTokenURL is the default endpoint for exchanging a GCP service account.
+	gcrTokenURL = "https://oauth2.googleapis.com/token"
+	// gcrUsername is the user name used with a GCP access token.
+	gcrUsername = "oauth2accesstoken"
+	// acrScope is the OAuth scope requested for the Azure access token.
+	acrScope = "https://management.azure.com/.default"
+	// acrUsername is the user name used with an ACR refresh token.
+	acrUsername = "00000000-0000-0000-0000-000000000000"
+)
+
+// Credential represents the configuration for exchanging
+// cloud credentials for short-lived registry tokens.
+type Credential struct {
+	// enables overriding the endpoint used for exchanging tokens
+	Endpoint string
+	// enables overriding the registry endpoint used for exchanging ACR tokens
+	ExchangeEndpoint string `json:"exchange_endpoint"`
+	// access key id for AWS (ecr)
+	AccessKeyID string `json:"access_key_id"`
+	// secret access key for AWS (ecr)
+	SecretAccessKey string `json:"secret_access_key"`
+	// session token for temporary AWS credentials (ecr)
+	SessionToken string `json:"session_token"`
+	// region of the AWS registry, derived from the registry name by default (ecr)
+	Region string
+	// service account key in JSON format or a path to the key file (gcr)
+	ServiceAccount string `json:"service_account"`
+	// tenant id of the Azure service principal (acr)
+	TenantID string `json:"tenant_id"`
+	// client id of the Azure service principal (acr)
+	ClientID string `json:"client_id"`
+	// client secret of the Azure service principal (acr)
+	ClientSecret string `json:"client_secret"`
+}
+
+// secrets returns the secret values of the cloud credentials.
+func (c *Credential) secrets() []string {
+	values := []string{c.SecretAccessKey, c.SessionToken, c.ClientSecret}
+
+	// check if the service account key is provided instead of a path to the key file
+	if strings.HasPrefix(strings.TrimSpace(c.ServiceAccount), "{") {
+		values = append(values, c.ServiceAccount)
+	}
+
+	return values
+}
+
+var (
+	// credentialHelpers represents the Docker credential helper binaries
+	// for each registry-native helper. When the binary is available in
+	// the image, Docker resolves credentials for the registry itself.
+	credentialHelpers = map[string]string{
+		ecrHelper: "ecr-login",
+		gcrHelper: "gcr",
+		acrHelper: "acr-env",
+	}
+
+	// httpClient represents the client used for exchanging tokens.
+	httpClient = &http.Client{Timeout: 30 * time.Second}
+
+	// lookPath represents the function for finding credential helper binaries.
+	lookPath = exec.LookPath
+
+	// credentialFlags represents for credential settings on the cli.
+	credentialFlags = []cli.Flag{
+		&cli.StringFlag{
+			Name:  "credential.endpoint",
+			Usage: "enables overriding the endpoint used for exchanging tokens",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_CREDENTIAL_ENDPOINT"),
+				cli.EnvVar("DOCKER_CREDENTIAL_ENDPOINT"),
+				cli.File("/vela/parameters/docker/credential_endpoint"),
+				cli.File("/vela/secrets/docker/credential_endpoint"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "credential.exchange-endpoint",
+			Usage: "enables overriding the registry endpoint used for exchanging ACR tokens",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_CREDENTIAL_EXCHANGE_ENDPOINT"),
+				cli.EnvVar("DOCKER_CREDENTIAL_EXCHANGE_ENDPOINT"),
+				cli.File("/vela/parameters/docker/credential_exchange_endpoint"),
+				cli.File("/vela/secrets/docker/credential_exchange_endpoint"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "credential.access-key-id",
+			Usage: "access key id for AWS (ecr)",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_AWS_ACCESS_KEY_ID"),
+				cli.EnvVar("DOCKER_AWS_ACCESS_KEY_ID"),
+				cli.EnvVar("AWS_ACCESS_KEY_ID"),
+				cli.File("/vela/parameters/docker/aws_access_key_id"),
+				cli.File("/vela/secrets/docker/aws_access_key_id"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "credential.secret-access-key",
+			Usage: "secret access key for AWS (ecr)",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_AWS_SECRET_ACCESS_KEY"),
+				cli.EnvVar("DOCKER_AWS_SECRET_ACCESS_KEY"),
+				cli.EnvVar("AWS_SECRET_ACCESS_KEY"),
+				cli.File("/vela/parameters/docker/aws_secret_access_key"),
+				cli.File("/vela/secrets/docker/aws_secret_access_key"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "credential.session-token",
+			Usage: "session token for temporary AWS credentials (ecr)",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_AWS_SESSION_TOKEN"),
+				cli.EnvVar("DOCKER_AWS_SESSION_TOKEN"),
+				cli.EnvVar("AWS_SESSION_TOKEN"),
+				cli.File("/vela/parameters/docker/aws_session_token"),
+				cli.File("/vela/secrets/docker/aws_session_token"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "credential.region",
+			Usage: "region of the AWS registry (ecr)",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_AWS_REGION"),
+				cli.EnvVar("DOCKER_AWS_REGION"),
+				cli.EnvVar("AWS_REGION"),
+				cli.EnvVar("AWS_DEFAULT_REGION"),
+				cli.File("/vela/parameters/docker/aws_region"),
+				cli.File("/vela/secrets/docker/aws_region"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "credential.service-account",
+			Usage: "service account key in JSON format or a path to the key file (gcr)",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_GOOGLE_CREDENTIALS"),
+				cli.EnvVar("DOCKER_GOOGLE_CREDENTIALS"),
+				cli.EnvVar("GOOGLE_CREDENTIALS"),
+				cli.EnvVar("GOOGLE_APPLICATION_CREDENTIALS"),
+				cli.File("/vela/parameters/docker/google_credentials"),
+				cli.File("/vela/secrets/docker/google_credentials"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "credential.tenant-id",
+			Usage: "tenant id of the Azure service principal (acr)",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_AZURE_TENANT_ID"),
+				cli.EnvVar("DOCKER_AZURE_TENANT_ID"),
+				cli.EnvVar("AZURE_TENANT_ID"),
+				cli.File("/vela/parameters/docker/azure_tenant_id"),
+				cli.File("/vela/secrets/docker/azure_tenant_id"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "credential.client-id",
+			Usage: "client id of the Azure service principal (acr)",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_AZURE_CLIENT_ID"),
+				cli.EnvVar("DOCKER_AZURE_CLIENT_ID"),
+				cli.EnvVar("AZURE_CLIENT_ID"),
+				cli.File("/vela/parameters/docker/azure_client_id"),
+				cli.File("/vela/secrets/docker/azure_client_id"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "credential.client-secret",
+			Usage: "client secret of the Azure service principal (acr)",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_AZURE_CLIENT_SECRET"),
+				cli.EnvVar("DOCKER_AZURE_CLIENT_SECRET"),
+				cli.EnvVar("AZURE_CLIENT_SECRET"),
+				cli.File("/vela/parameters/docker/azure_client_secret"),
+				cli.File("/vela/secrets/docker/azure_client_secret"),
+			),
+		},
+	}
+)
+
+// credentialHelper returns the name of the Docker credential helper
+// binary for the registry-native helper if it is available in the image.
+func credentialHelper(helper string) (string, bool) {
+	name, ok := credentialHelpers[helper]
+	if !ok {
+		return "", false
+	}
+
+	_, err := lookPath("docker-credential-" + name)
+	if err != nil {
+		return "", false
+	}
+
+	return name, true
+}
+
+// Exchange trades the credentials for a short-lived token
+// and returns the user name and password for the registry.
+func (c *Credential) Exchange(ctx context.Context, helper, registry string) (string, string, error) {
+	logrus.Tracef("exchanging %s credentials for registry %s", helper, registry)
+
+	switch helper {
+	case ecrHelper:
+		return c.ecr(ctx, registry)
+	case gcrHelper:
+		return c.gcr(ctx)
+	case acrHelper:
+		return c.acr(ctx, registry)
+	default:
+		return "", "", fmt.Errorf("invalid credential helper %s", helper)
+	}
+}
+
+// Validate verifies the Credential is properly configured for the helper.
+func (c *Credential) Validate(helper, registry string) error {
+	logrus.Trace("validating credential plugin configuration")
+
+	// check if any credentials were provided
+	if c == nil {
+		return fmt.Errorf("no %s credentials provided for registry %s", helper, registry)
+	}
+
+	switch helper {
+	case ecrHelper:
+		// check if the AWS access key is provided
+		if len(c.AccessKeyID) == 0 || len(c.SecretAccessKey) == 0 {
+			return fmt.Errorf("no AWS access key provided for registry %s", registry)
+		}
+
+		// check if the AWS region is provided or can be derived
+		if len(c.region(registry)) == 0 {
+			return fmt.Errorf("no AWS region provided for registry %s", registry)
+		}
+	case gcrHelper:
+		// check if the GCP service account is provided
+		if len(c.ServiceAccount) == 0 {
+			return fmt.Errorf("no GCP service account provided for registry %s", registry)
+		}
+	case acrHelper:
+		// check if the Azure service principal is provided
+		if len(c.TenantID) == 0 || len(c.ClientID) == 0 || len(c.ClientSecret) == 0 {
+			return fmt.Errorf("no Azure service principal provided for registry %s", registry)
+		}
+	default:
+		return fmt.Errorf("invalid credential_helper %s for registry %s: expected one of ecr, gcr or acr", helper, registry)
+	}
+
+	return nil
+}
+
+// region returns the AWS region for the registry, preferring
+// the configured region over the one in the registry name.
+func (c *Credential) region(registry string) string {
+	// check if the region is provided
+	if len(c.Region) > 0 {
+		return c.Region
+	}
+
+	// derive the region from a name like <account>.dkr.ecr.<region>.amazonaws.com
+	parts := strings.Split(registryHost(registry), ".")
+	for i := 0; i+2 < len(parts); i++ {
+		if parts[i] == "dkr" && parts[i+1] == "ecr" {
+			return parts[i+2]
+		}
+	}
+
+	return ""
+}
+
+// ecr exchanges the AWS access key for an ECR authorization token.
+func (c *Credential) ecr(ctx context.Context, registry string) (string, string, error) {
+	region := c.region(registry)
+
+	// set the endpoint for the ECR API
+	endpoint := c.Endpoint
+	if len(endpoint) == 0 {
+		endpoint = fmt.Sprintf("https://api.ecr.%s.amazonaws.com/", region)
+	}
+
+	body := []byte("{}")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", "", err
+	}
+
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", ecrTarget)
+
+	// sign the request with the AWS access key
+	c.sign(req, body, region, "ecr", time.Now())
+
+	// variable to store the response from the ECR API
+	var resp struct {
+		AuthorizationData []struct {
+			AuthorizationToken string `json:"authorizationToken"`
+		} `json:"authorizationData"`
+	}
+
+	err = doJSON(req, &resp)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to exchange ECR token: %w", err)
+	}
+
+	// check if an authorization token was returned
+	if len(resp.AuthorizationData) == 0 {
+		return "", "", fmt.Errorf("unable to exchange ECR token: no authorization data returned")
+	}
+
+	// decode the token in the format of user:password
+	token, err := base64.StdEncoding.DecodeString(resp.AuthorizationData[0].AuthorizationToken)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to decode ECR token: %w", err)
+	}
+
+	username, password, ok := strings.Cut(string(token), ":")
+	if !ok {
+		return "", "", fmt.Errorf("unable to decode ECR token: invalid format")
+	}
+
+	return username, password, nil
+}
+
+// sign adds the AWS Signature Version 4 headers to the request.
+//
+// https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_sigv-create-signed-request.html
+func (c *Credential) sign(req *http.Request, body []byte, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	// check if temporary credentials are provided
+	if len(c.SessionToken) > 0 {
+		req.Header.Set("X-Amz-Security-Token", c.SessionToken)
+	}
+
+	// collect the headers to sign including the host
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", k, headers[k])
+	}
+
+	signedHeaders := strings.Join(names, ";")
+
+	// set the path for the canonical request
+	path := req.URL.EscapedPath()
+	if len(path) == 0 {
+		path = "/"
+	}
+
+	// sort the query for the canonical request
+	query := strings.ReplaceAll(req.URL.Query().Encode(), "+", "%20")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		query,
+		canonicalHeaders.String(),
+		signedHeaders,
+		sha256Hex(body),
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", date, region, service)
+
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	// derive the signing key for the scope
+	key := hmacSHA256([]byte("AWS4"+c.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.AccessKeyID, scope, signedHeaders, signature,
+	))
+}
+
+// gcr exchanges the GCP service account for an access token.
+//
+// https://developers.google.com/identity/protocols/oauth2/service-account#httprest
+func (c *Credential) gcr(ctx context.Context) (string, string, error) {
+	key := []byte(c.ServiceAccount)
+
+	// check if the service account is a path to the key file
+	if !strings.HasPrefix(strings.TrimSpace(c.ServiceAccount), "{") {
+		data, err := (&afero.Afero{Fs: appFS}).ReadFile(c.ServiceAccount)
+		if err != nil {
+			return "", "", fmt.Errorf("unable to read GCP service account: %w", err)
+		}
+
+		key = data
+	}
+
+	// variable to store the service account key
+	var account struct {
+		ClientEmail  string `json:"client_email"`
+		PrivateKey   string `json:"private_key"`
+		PrivateKeyID string `json:"private_key_id"`
+		TokenURI     string `json:"token_uri"`
+	}
+
+	err := json.Unmarshal(key, &account)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to parse GCP service account: %w", err)
+	}
+
+	// set the endpoint for exchanging the service account
+	endpoint := c.Endpoint
+	if len(endpoint) == 0 {
+		endpoint = account.TokenURI
+	}
+
+	if len(endpoint) == 0 {
+		endpoint = gcrTokenURL
+	}
+
+	// create the signed assertion for the service account
+	assertion, err := signJWT(account.PrivateKey, account.PrivateKeyID, map[string]any{
+		"iss":   account.ClientEmail,
+		"scope": gcrScope,
+		"aud":   endpoint,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("unable to sign GCP service account assertion: %w", err)
+	}
+
+	// variable to store the response from the token endpoint
+	var resp struct {
+		AccessToken string `json:"access_token"`
+	}
+
+	err = postForm(ctx, endpoint, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}, &resp)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to exchange GCP token: %w", err)
+	}
+
+	return gcrUsername, resp.AccessToken, nil
+}
+
+// acr exchanges the Azure service principal for an ACR refresh token.
+//
+// https://github.com/Azure/acr/blob/main/docs/AAD-OAuth.md
+func (c *Credential) acr(ctx context.Context, registry string) (string, string, error) {
+	host := registryHost(registry)
+
+	// set the endpoint for the Azure AD token
+	endpoint := c.Endpoint
+	if len(endpoint) == 0 {
+		endpoint = fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/token", url.PathEscape(c.TenantID))
+	}
+
+	// variable to store the response from the Azure AD token endpoint
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+
+	err := postForm(ctx, endpoint, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {c.ClientID},
+		"client_secret": {c.ClientSecret},
+		"scope":         {acrScope},
+	}, &token)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to exchange Azure AD token: %w", err)
+	}
+
+	// set the endpoint for the ACR token exchange
+	exchange := c.ExchangeEndpoint
+	if len(exchange) == 0 {
+		exchange = fmt.Sprintf("https://%s/oauth2/exchange", host)
+	}
+
+	// variable to store the response from the ACR token exchange
+	var refresh struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	err = postForm(ctx, exchange, url.Values{
+		"grant_type":   {"access_token"},
+		"service":      {host},
+		"tenant":       {c.TenantID},
+		"access_token": {token.AccessToken},
+	}, &refresh)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to exchange ACR token: %w", err)
+	}
+
+	return acrUsername, refresh.RefreshToken, nil
+}
+
+// postForm sends the form to the endpoint and decodes the JSON response.
+func postForm(ctx context.Context, endpoint string, form url.Values, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return doJSON(req, v)
+}
+
+// doJSON sends the request and decodes the JSON response.
+func doJSON(req *http.Request, v any) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	// check if the request was successful
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%s %s returned %s", req.Method, req.URL.Redacted(), resp.Status)
+	}
+
+	return json.Unmarshal(body, v)
+}
+
+// signJWT creates a JWT signed with RS256 using the PEM encoded private key.
+func signJWT(privateKey, keyID string, claims map[string]any) (string, error) {
+	block, _ := pem.Decode([]byte(privateKey))
+	if block == nil {
+		return "", fmt.Errorf("invalid private key")
+	}
+
+	// parse the private key in PKCS #8 or PKCS #1 form
+	var key *rsa.PrivateKey
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err == nil {
+		var ok bool
+
+		key, ok = parsed.(*rsa.PrivateKey)
+		if !ok {
+			return "", fmt.Errorf("private key is not an RSA key")
+		}
+	} else {
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return "", fmt.Errorf("invalid private key: %w", err)
+		}
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": keyID})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(unsigned))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// sha256Hex returns the hex encoded SHA-256 digest of the data.
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of the data with the key.
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+
+	return h.Sum(nil)
+}
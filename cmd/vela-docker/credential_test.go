@@ -0,0 +1,449 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+func TestDocker_Credential_sign(t *testing.T) {
+	// setup types
+	//
+	// https://docs.aws.amazon.com/IAM/latest/UserGuide/create-signed-request.html
+	c := &Credential{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+
+	req, err := http.NewRequestWithContext(
+		t.Context(),
+		http.MethodGet,
+		"https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08",
+		nil,
+	)
+	if err != nil {
+		t.Fatalf("NewRequest returned err: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date, " +
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+
+	c.sign(req, nil, "us-east-1", "iam", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	got := req.Header.Get("Authorization")
+	if got != want {
+		t.Errorf("sign is %s, want %s", got, want)
+	}
+}
+
+func TestDocker_Credential_Exchange_ECR(t *testing.T) {
+	// setup server
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != ecrTarget {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") {
+			w.WriteHeader(http.StatusForbidden)
+
+			return
+		}
+
+		token := base64.StdEncoding.EncodeToString([]byte("AWS:ecrToken"))
+
+		_, _ = w.Write([]byte(`{"authorizationData": [{"authorizationToken": "` + token + `"}]}`))
+	}))
+	defer s.Close()
+
+	// setup types
+	c := &Credential{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "superSecretKey",
+		Endpoint:        s.URL,
+	}
+
+	username, password, err := c.Exchange(t.Context(), ecrHelper, "123456789012.dkr.ecr.us-east-2.amazonaws.com")
+	if err != nil {
+		t.Errorf("Exchange returned err: %v", err)
+	}
+
+	if username != "AWS" || password != "ecrToken" {
+		t.Errorf("Exchange is %s:%s, want AWS:ecrToken", username, password)
+	}
+}
+
+func TestDocker_Credential_Exchange_GCR(t *testing.T) {
+	// setup key
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey returned err: %v", err)
+	}
+
+	// setup server
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		parts := strings.Split(r.FormValue("assertion"), ".")
+		if len(parts) != 3 {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+
+		claims := make(map[string]any)
+		_ = json.Unmarshal(payload, &claims)
+
+		if claims["iss"] != "vela@example.iam.gserviceaccount.com" {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"access_token": "gcrToken"}`))
+	}))
+	defer s.Close()
+
+	// setup types
+	account, _ := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"client_email":   "vela@example.iam.gserviceaccount.com",
+		"private_key_id": "1",
+		"private_key": string(pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(key),
+		})),
+		"token_uri": s.URL,
+	})
+
+	c := &Credential{
+		ServiceAccount: string(account),
+	}
+
+	username, password, err := c.Exchange(t.Context(), gcrHelper, "us-docker.pkg.dev")
+	if err != nil {
+		t.Errorf("Exchange returned err: %v", err)
+	}
+
+	if username != gcrUsername || password != "gcrToken" {
+		t.Errorf("Exchange is %s:%s, want %s:gcrToken", username, password, gcrUsername)
+	}
+}
+
+func TestDocker_Credential_Exchange_ACR(t *testing.T) {
+	// setup server
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("client_secret") != "superSecretPassword" {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"access_token": "aadToken"}`))
+	})
+
+	mux.HandleFunc("/oauth2/exchange", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("access_token") != "aadToken" || r.FormValue("service") != "octocat.azurecr.io" {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"refresh_token": "acrToken"}`))
+	})
+
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	// setup types
+	c := &Credential{
+		TenantID:         "tenant",
+		ClientID:         "client",
+		ClientSecret:     "superSecretPassword",
+		Endpoint:         s.URL + "/token",
+		ExchangeEndpoint: s.URL + "/oauth2/exchange",
+	}
+
+	username, password, err := c.Exchange(t.Context(), acrHelper, "octocat.azurecr.io")
+	if err != nil {
+		t.Errorf("Exchange returned err: %v", err)
+	}
+
+	if username != acrUsername || password != "acrToken" {
+		t.Errorf("Exchange is %s:%s, want %s:acrToken", username, password, acrUsername)
+	}
+}
+
+func TestDocker_Credential_Exchange_Error(t *testing.T) {
+	// setup server
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer s.Close()
+
+	// setup types
+	c := &Credential{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "superSecretKey",
+		Region:          "us-east-2",
+		TenantID:        "tenant",
+		ClientID:        "client",
+		ClientSecret:    "superSecretPassword",
+		Endpoint:        s.URL,
+	}
+
+	for _, helper := range []string{ecrHelper, acrHelper, "foo"} {
+		_, _, err := c.Exchange(t.Context(), helper, "index.docker.io")
+		if err == nil {
+			t.Errorf("Exchange should have returned err for %s", helper)
+		}
+	}
+}
+
+func TestDocker_Credential_Validate(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		failure    bool
+		helper     string
+		registry   string
+		credential *Credential
+	}{
+		{
+			failure:  false,
+			helper:   ecrHelper,
+			registry: "123456789012.dkr.ecr.us-east-2.amazonaws.com",
+			credential: &Credential{
+				AccessKeyID:     "AKIDEXAMPLE",
+				SecretAccessKey: "superSecretKey",
+			},
+		},
+		{
+			failure:  true,
+			helper:   ecrHelper,
+			registry: "registry.example.com",
+			credential: &Credential{
+				AccessKeyID:     "AKIDEXAMPLE",
+				SecretAccessKey: "superSecretKey",
+			},
+		},
+		{
+			failure:    true,
+			helper:     ecrHelper,
+			registry:   "123456789012.dkr.ecr.us-east-2.amazonaws.com",
+			credential: &Credential{},
+		},
+		{
+			failure:  false,
+			helper:   gcrHelper,
+			registry: "gcr.io",
+			credential: &Credential{
+				ServiceAccount: "/path/to/key.json",
+			},
+		},
+		{
+			failure:    true,
+			helper:     gcrHelper,
+			registry:   "gcr.io",
+			credential: &Credential{},
+		},
+		{
+			failure:  false,
+			helper:   acrHelper,
+			registry: "octocat.azurecr.io",
+			credential: &Credential{
+				TenantID:     "tenant",
+				ClientID:     "client",
+				ClientSecret: "superSecretPassword",
+			},
+		},
+		{
+			failure:  true,
+			helper:   acrHelper,
+			registry: "octocat.azurecr.io",
+			credential: &Credential{
+				TenantID: "tenant",
+			},
+		},
+		{
+			failure:  true,
+			helper:   acrHelper,
+			registry: "octocat.azurecr.io",
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		err := test.credential.Validate(test.helper, test.registry)
+
+		if test.failure {
+			if err == nil {
+				t.Errorf("Validate should have returned err for %s", test.registry)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Validate returned err: %v", err)
+		}
+	}
+}
+
+func TestDocker_Registry_Exchange(t *testing.T) {
+	// setup credential helper lookup
+	lookPath = func(string) (string, error) {
+		return "", errors.New("not found")
+	}
+
+	t.Cleanup(func() {
+		lookPath = exec.LookPath
+	})
+
+	// setup server
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		token := base64.StdEncoding.EncodeToString([]byte("AWS:ecrToken"))
+
+		_, _ = w.Write([]byte(`{"authorizationData": [{"authorizationToken": "` + token + `"}]}`))
+	}))
+	defer s.Close()
+
+	// setup types
+	r := &Registry{
+		Name:             "123456789012.dkr.ecr.us-east-2.amazonaws.com",
+		CredentialHelper: ecrHelper,
+		Credential: &Credential{
+			AccessKeyID:     "AKIDEXAMPLE",
+			SecretAccessKey: "superSecretKey",
+			Endpoint:        s.URL,
+		},
+	}
+
+	err := r.Validate()
+	if err != nil {
+		t.Errorf("Validate returned err: %v", err)
+	}
+
+	err = r.Exchange(t.Context())
+	if err != nil {
+		t.Errorf("Exchange returned err: %v", err)
+	}
+
+	if r.Username != "AWS" || r.Password != "ecrToken" {
+		t.Errorf("Exchange is %s:%s, want AWS:ecrToken", r.Username, r.Password)
+	}
+}
+
+func TestDocker_Registry_Validate_CredentialHelper_Registries(t *testing.T) {
+	// setup credential helper lookup
+	lookPath = func(file string) (string, error) {
+		return "/usr/local/bin/" + file, nil
+	}
+
+	t.Cleanup(func() {
+		lookPath = exec.LookPath
+	})
+
+	// setup types
+	r := &Registry{
+		Name:             "123456789012.dkr.ecr.us-east-2.amazonaws.com",
+		CredentialHelper: ecrHelper,
+		Registries: []*Registry{
+			{Name: "ghcr.io"},
+		},
+	}
+
+	// verify the additional registries are validated with a credential helper
+	err := r.Validate()
+	if err == nil || !strings.Contains(err.Error(), "ghcr.io") {
+		t.Errorf("Validate should have returned err for registry ghcr.io: %v", err)
+	}
+
+	r.Registries[0].Username = "octocat"
+	r.Registries[0].Password = "superSecretPassword"
+
+	err = r.Validate()
+	if err != nil {
+		t.Errorf("Validate returned err: %v", err)
+	}
+}
+
+func TestDocker_Registry_Write_CredentialHelper(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup credential helper lookup
+	lookPath = func(file string) (string, error) {
+		return "/usr/local/bin/" + file, nil
+	}
+
+	t.Cleanup(func() {
+		lookPath = exec.LookPath
+	})
+
+	// setup types
+	r := &Registry{
+		Name:             "123456789012.dkr.ecr.us-east-2.amazonaws.com",
+		CredentialHelper: ecrHelper,
+	}
+
+	err := r.Validate()
+	if err != nil {
+		t.Errorf("Validate returned err: %v", err)
+	}
+
+	err = r.Exchange(t.Context())
+	if err != nil {
+		t.Errorf("Exchange returned err: %v", err)
+	}
+
+	err = r.Write()
+	if err != nil {
+		t.Errorf("Write returned err: %v", err)
+	}
+
+	data, err := afero.ReadFile(appFS, dockerConfigPath())
+	if err != nil {
+		t.Errorf("ReadFile returned err: %v", err)
+	}
+
+	got := new(config)
+
+	err = json.Unmarshal(data, got)
+	if err != nil {
+		t.Errorf("Unmarshal returned err: %v", err)
+	}
+
+	if got.CredHelpers[r.Name] != "ecr-login" {
+		t.Errorf("Write credHelpers is %v, want ecr-login for %s", got.CredHelpers, r.Name)
+	}
+
+	if _, ok := got.Auths[r.Name]; ok {
+		t.Errorf("Write auths should not contain %s", r.Name)
+	}
+}
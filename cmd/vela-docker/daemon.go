@@ -4,8 +4,12 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"os"
 	"os/exec"
-	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -17,6 +21,12 @@ type (
 	Daemon struct {
 		// enables specifying a network bridge IP
 		Bip string
+		// enables managing the size and sharing of the data root mounted from the host
+		Cache *Cache
+		// enables setting arbitrary keys of the daemon.json file
+		Config map[string]any `json:"-"`
+		// enables setting the root directory for the state of the daemon
+		DataRoot string `json:"data_root"`
 		// used for translating the storage configuration
 		DNS *DNS
 		// enables setting custom storage options
@@ -31,12 +41,33 @@ type (
 		LogLevel string `json:"log_level"`
 		// enable setting the containers network MTU
 		MTU int
+		// enables using an existing daemon instead of starting one
+		Mode string
+		// used for configuring how long to wait for the daemon to be ready
+		Readiness *Readiness
 		// enables setting a preferred Docker registry mirror
 		RegistryMirrors []string `json:"registry_mirrors"`
+		// enables running the daemon without root privileges
+		Rootless bool
+		// enables setting the maximum time to wait for the daemon to stop (default 30s)
+		StopTimeout Duration `json:"stop_timeout"`
+		// used for running the daemon and the readiness checks
+		Runner Runner `json:"-"`
 		// used for translating the storage configuration
 		Storage *Storage
 		// enables setting custom storage options
 		StorageRaw string
+
+		// command for the daemon started by the plugin
+		cmd *exec.Cmd
+		// closed when the daemon started by the plugin exits
+		done chan struct{}
+		// error the daemon started by the plugin exited with
+		exitErr error
+		// lock file held on the data root mounted from the host
+		lock *os.File
+		// enables pruning once the daemon started by the plugin accepted requests
+		ready bool
 	}
 
 	// DNS represents the "dns" prefixed flags within the "dockerd" command.
@@ -56,6 +87,25 @@ type (
 	}
 )
 
+const (
+	// daemonTailLines is the number of lines of daemon output
+	// included when the daemon fails to become ready.
+	daemonTailLines = 25
+
+	// defaultStopTimeout is the default maximum time to wait
+	// for the daemon to stop before it is killed.
+	defaultStopTimeout = 30 * time.Second
+
+	// defaultDockerHost is the address the daemon started by the plugin listens on.
+	defaultDockerHost = "unix:///var/run/docker.sock"
+
+	// embeddedMode is the daemon mode starting dockerd inside the plugin.
+	embeddedMode = "embedded"
+	// externalMode is the daemon mode using an existing daemon
+	// reachable with the DOCKER_HOST and TLS environment variables.
+	externalMode = "external"
+)
+
 // daemonFlags represents for daemon settings on the cli.
 var daemonFlags = []cli.Flag{
 	&cli.StringFlag{
@@ -70,155 +120,276 @@ var daemonFlags = []cli.Flag{
 	},
 }
 
-// Command formats and outputs the Build command from
-// the provided configuration to push a Docker image.
+// Command formats and outputs the command for starting
+// the Docker daemon with the rendered daemon.json file.
 func (d *Daemon) Command(ctx context.Context) *exec.Cmd {
 	logrus.Trace("creating dockerd command from plugin configuration")
 
 	// variable to store flags for command
-	flags := []string{"--data-root=/var/lib/docker", "--host=unix:///var/run/docker.sock"}
+	var flags []string
 
-	// check if Bip is provided
-	if len(d.Bip) > 0 {
-		// add flag for Bip from provided build command
-		flags = append(flags, "--bip", d.Bip)
-	}
+	// add flag for the rendered daemon.json file
+	flags = append(flags, "--config-file", d.configPath())
 
-	// add flags for DNS configuration
-	flags = append(flags, d.DNS.Flags()...)
+	// check if the daemon runs without root privileges
+	if d.rootless() {
+		cmd := exec.CommandContext(ctx, _dockerdRootless, flags...)
 
-	// check if Experimental is provided
-	if d.Experimental {
-		// add flag for Experimental from provided build command
-		flags = append(flags, "--experimental")
+		// set the directory for the socket of the daemon
+		cmd.Env = append(os.Environ(), "XDG_RUNTIME_DIR="+runtimeDir())
+
+		return cmd
 	}
 
-	// iterate through the insecure registries provided
-	for _, i := range d.InsecureRegistries {
-		// add flag for InsecureRegistries from provided build command
-		flags = append(flags, "--insecure-registry", i)
+	return exec.CommandContext(ctx, _dockerd, flags...)
+}
+
+// Exec formats and runs the commands for starting the Docker daemon
+// and waits for the daemon to be ready to accept connections.
+//
+// An external daemon is not started and is only checked to be ready.
+func (d *Daemon) Exec(ctx context.Context) error {
+	// check if an existing daemon is used
+	if d.External() {
+		return d.wait(ctx)
 	}
 
-	// check if Experimental is provided
-	if d.IPV6 {
-		// add flag for Experimental from provided build command
-		flags = append(flags, "--ipv6")
+	logrus.Trace("running dockerd with provided configuration")
+
+	// default to running the daemon on the host
+	runner := runnerOrExec(d.Runner)
+
+	// create the daemon.json file for the daemon
+	err := d.Write()
+	if err != nil {
+		return err
 	}
 
-	// check if LogLevel is provided
-	if len(d.LogLevel) > 0 {
-		// add flag for LogLevel from provided build command
-		flags = append(flags, "--log-level", d.LogLevel)
-	} else {
-		// add flag for LogLevel hardcoded to error level logging
-		//
-		// this helps to drastically reduce the level of logs
-		// output by the plugin when starting up the docker daemon
-		flags = append(flags, "--log-level", "error")
+	// verify the daemon accepts the configuration before starting it
+	err = execCmd(runner, d.validateCmd(ctx))
+	if err != nil {
+		return fmt.Errorf("invalid daemon configuration %s: %w", d.configPath(), err)
 	}
 
-	// check if MTU is provided
-	if d.MTU > 0 {
-		// add flag for MTU from provided build command
-		flags = append(flags, "--mtu", strconv.Itoa(d.MTU))
+	// check if the daemon runs without root privileges
+	if d.rootless() {
+		// create the directory for the socket of the daemon
+		err = appFS.MkdirAll(runtimeDir(), 0700)
+		if err != nil {
+			return fmt.Errorf("unable to create runtime directory for rootless docker daemon: %w", err)
+		}
 	}
 
-	// iterate through the registry mirrors provided
-	for _, r := range d.RegistryMirrors {
-		// add flag for RegistryMirrors from provided build command
-		flags = append(flags, "--registry-mirror", r)
+	// lock the data root shared with other steps on the host
+	err = d.Lock(ctx)
+	if err != nil {
+		return err
 	}
 
-	// add flags for Storage configuration
-	flags = append(flags, d.Storage.Flags()...)
+	// create the daemon command
+	//
+	// the daemon is not killed when the context is canceled
+	// since it is stopped gracefully by the plugin instead
+	cmd := d.Command(context.WithoutCancel(ctx))
 
-	// the plugin accepts configuration
-	return exec.CommandContext(ctx, _dockerd, flags...)
-}
+	// capture the last lines of the daemon output for reporting failures
+	tail := newTailWriter(daemonTailLines)
 
-// Exec formats and runs the commands for pushing a Docker image.
-func (d *Daemon) Exec(ctx context.Context) error {
-	logrus.Trace("running dockerd with provided configuration")
+	// redact the secret values from the daemon output
+	stdout := secretMask.writer(io.MultiWriter(runner.Stdout(), tail))
+	stderr := secretMask.writer(io.MultiWriter(runner.Stderr(), tail))
+
+	// set command stdout to the runner stdout and the captured output
+	cmd.Stdout = stdout
+	// set command stderr to the runner stderr and the captured output
+	cmd.Stderr = stderr
+
+	// output "trace" string for command
+	fmt.Fprintln(runner.Stdout(), "$", secretMask.redact(strings.Join(cmd.Args, " ")))
+
+	// start the daemon in the background
+	err = runner.Start(cmd)
+	if err != nil {
+		return fmt.Errorf("unable to start docker daemon: %w", err)
+	}
+
+	d.cmd = cmd
+	d.done = make(chan struct{})
 
-	// create the push command for the file
-	cmd := d.Command(ctx)
+	// capture the exit of the daemon
+	exited := make(chan error, 1)
 
-	// start the daemon in a thread
 	go func() {
-		err := execCmd(cmd)
-		if err != nil {
-			logrus.Error(err)
-		}
-	}()
+		d.exitErr = runner.Wait(cmd)
 
-	// poll the docker daemon to ensures the daemon is
-	// ready to accept connections.
-	retryLimit := 5
+		// write the remaining output of the daemon
+		_ = stdout.Flush()
+		_ = stderr.Flush()
 
-	// iterate through with a retryLimit
-	for i := 0; i < retryLimit; i++ {
-		err := versionCmd(ctx).Run()
-		if err == nil {
-			break
-		}
+		exited <- d.exitErr
+		close(d.done)
+	}()
 
-		// sleep in between retries
-		time.Sleep(time.Duration(i) * time.Second)
+	// poll the docker daemon to ensure the daemon is
+	// ready to accept connections
+	err = d.Readiness.Wait(ctx, exited, func(ctx context.Context) error {
+		return runner.Run(versionCmd(ctx))
+	})
+	if err != nil {
+		return fmt.Errorf("%w\n\nlast %d lines of dockerd output:\n%s", err, daemonTailLines, tail)
 	}
 
+	d.ready = true
+
 	return nil
 }
 
-// Flags formats and outputs the flags for
-// configuring a Docker daemon DNS settings.
-func (d *DNS) Flags() []string {
-	// variable to store flags for command
-	var flags []string
+// Stop stops the daemon started by the plugin and reports the exit status.
+//
+// The daemon is sent a SIGTERM and is killed when it does not
+// exit within the stop timeout.
+func (d *Daemon) Stop() error {
+	// check if any daemon settings are provided
+	if d == nil {
+		return nil
+	}
+
+	// release the data root once the daemon stopped
+	defer d.Unlock()
+
+	// check if the daemon was started by the plugin
+	if d.cmd == nil {
+		return nil
+	}
+
+	runner := runnerOrExec(d.Runner)
 
-	// check if any dns flags are set
-	if d != nil {
-		// check if Servers is provided
-		if d.Servers != nil {
-			for _, d := range d.Servers {
-				// add flag for DNS from provided build command
-				flags = append(flags, "--dns", d)
+	// check if the daemon already exited
+	select {
+	case <-d.done:
+		return d.report()
+	default:
+	}
+
+	timeout := defaultStopTimeout
+	if d.StopTimeout > 0 {
+		timeout = time.Duration(d.StopTimeout)
+	}
+
+	logrus.Infof("stopping docker daemon (timeout %s)", timeout)
+
+	err := runner.Signal(d.cmd, syscall.SIGTERM)
+	if err != nil {
+		logrus.Warnf("unable to send SIGTERM to docker daemon: %v", err)
+	}
+
+	select {
+	case <-d.done:
+		return d.report()
+	case <-time.After(timeout):
+	}
+
+	logrus.Warnf("docker daemon did not stop within %s - killing it", timeout)
+
+	err = runner.Signal(d.cmd, os.Kill)
+	if err != nil {
+		return fmt.Errorf("unable to kill docker daemon: %w", err)
+	}
+
+	<-d.done
+
+	return fmt.Errorf("docker daemon killed after not stopping within %s: %w", timeout, d.exitErr)
+}
+
+// report returns the error the daemon started by the plugin exited with.
+func (d *Daemon) report() error {
+	// check if the daemon exited successfully
+	if d.exitErr == nil {
+		logrus.Info("docker daemon stopped with exit status 0")
+
+		return nil
+	}
+
+	return fmt.Errorf("docker daemon stopped with error: %w", d.exitErr)
+}
+
+// External returns true when the plugin uses an existing daemon.
+func (d *Daemon) External() bool {
+	return d != nil && d.Mode == externalMode
+}
+
+// Validate verifies the Daemon is properly configured.
+func (d *Daemon) Validate() error {
+	logrus.Trace("validating daemon plugin configuration")
+
+	// check if any daemon settings are provided
+	if d == nil {
+		return nil
+	}
+
+	switch d.Mode {
+	case "", embeddedMode:
+		// verify the daemon.json file can be rendered
+		_, err := d.Render()
+		if err != nil {
+			return err
+		}
+
+		// check if the cache is managed by the plugin
+		if d.Cache != nil {
+			err = d.Cache.Validate()
+			if err != nil {
+				return err
 			}
 		}
 
-		// check if Searches is provided
-		if len(d.Searches) > 0 {
-			for _, s := range d.Searches {
-				// add flag for DNS from provided build command
-				flags = append(flags, "--dns-search", s)
+		// check if the daemon runs without root privileges
+		if d.Rootless {
+			err = checkRootless(currentUser())
+			if err != nil {
+				return fmt.Errorf("daemon rootless mode is not supported in this environment:\n%w", err)
 			}
 		}
+	case externalMode:
+		// alert user the settings for starting the daemon are ignored
+		if d.configured() {
+			logrus.Warnf("daemon mode %s uses an existing daemon - ignoring settings for starting dockerd", externalMode)
+		}
+	default:
+		return fmt.Errorf("invalid daemon mode %q: options (%s|%s)", d.Mode, embeddedMode, externalMode)
 	}
 
-	return flags
+	return nil
+}
+
+// configured returns true when any settings for starting dockerd are provided.
+func (d *Daemon) configured() bool {
+	return len(d.settings()) > 0 || len(d.Config) > 0 || d.Cache != nil || d.Rootless
 }
 
-// Flags formats and outputs the flags for
-// configuring a Docker daemon DNS settings.
-func (s *Storage) Flags() []string {
-	// variable to store flags for command
-	var flags []string
+// wait waits for the existing daemon reachable with
+// DOCKER_HOST to be ready to accept connections.
+func (d *Daemon) wait(ctx context.Context) error {
+	host := os.Getenv("DOCKER_HOST")
+	if len(host) == 0 {
+		host = defaultDockerHost
+	}
 
-	// check if any storage flags are set
-	if s != nil {
-		// check if Driver is provided
-		if len(s.Driver) > 0 {
-			// add flag for Driver from provided build command
-			flags = append(flags, "--storage-driver", s.Driver)
-		}
+	logrus.Infof("daemon mode %s - using existing docker daemon at %s", externalMode, host)
 
-		// check if DNSSearch is provided
-		if len(s.Opts) > 0 {
-			for _, o := range s.Opts {
-				// add flag for DNS from provided build command
-				flags = append(flags, "--storage-opt", o)
-			}
-		}
+	// default to running the readiness checks on the host
+	runner := runnerOrExec(d.Runner)
+
+	// poll the docker daemon to ensure the daemon is
+	// ready to accept connections
+	//
+	// the daemon is not started by the plugin so it never exits
+	err := d.Readiness.Wait(ctx, nil, func(ctx context.Context) error {
+		return runner.Run(versionCmd(ctx))
+	})
+	if err != nil {
+		return fmt.Errorf("unable to reach docker daemon at %s: %w", host, err)
 	}
 
-	return flags
+	return nil
 }
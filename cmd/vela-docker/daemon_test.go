@@ -3,29 +3,19 @@
 package main
 
 import (
-	"fmt"
 	"os/exec"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/spf13/afero"
 )
 
 func TestDocker_Daemon_Command(t *testing.T) {
 	// setup types
 	d := &Daemon{
 		Bip: "192.168.1.5/24",
-		DNS: &DNS{
-			Servers:  []string{"10.20.1.2", "10.20.1.3"},
-			Searches: []string{"8.8.8.8"},
-		},
-		Experimental:       true,
-		InsecureRegistries: []string{"private.registry.com"},
-		IPV6:               true,
-		MTU:                1500,
-		RegistryMirrors:    []string{"mirror.registry.com"},
-		Storage: &Storage{
-			Driver: "overlay2",
-			Opts:   []string{"ftype=1"},
-		},
 	}
 
 	//nolint:gosec // this functionality is not exploitable the way
@@ -33,19 +23,8 @@ func TestDocker_Daemon_Command(t *testing.T) {
 	want := exec.CommandContext(
 		t.Context(),
 		_dockerd,
-		"--data-root=/var/lib/docker",
-		"--host=unix:///var/run/docker.sock",
-		fmt.Sprintf("--bip %s", d.Bip),
-		fmt.Sprintf("--dns %s --dns %s", d.DNS.Servers[0], d.DNS.Servers[1]),
-		fmt.Sprintf("--dns-search %s", d.DNS.Searches[0]),
-		"--experimental",
-		fmt.Sprintf("--insecure-registry %s", d.InsecureRegistries[0]),
-		"--ipv6",
-		"--log-level error",
-		fmt.Sprintf("--mtu %d", d.MTU),
-		fmt.Sprintf("--registry-mirror %s", d.RegistryMirrors[0]),
-		fmt.Sprintf("--storage-driver %s", d.Storage.Driver),
-		fmt.Sprintf("--storage-opt %s", d.Storage.Opts[0]),
+		"--config-file",
+		daemonConfigPath,
 	)
 
 	got := d.Command(t.Context())
@@ -53,3 +32,259 @@ func TestDocker_Daemon_Command(t *testing.T) {
 		t.Errorf("Command is %v, want %v", got, want)
 	}
 }
+
+func TestDocker_Daemon_Exec_Error(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	d := &Daemon{}
+
+	err := d.Exec(t.Context())
+	if err == nil {
+		t.Errorf("Exec should have returned err")
+	}
+}
+
+func TestDocker_Daemon_Exec_Tail(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name    string
+		results map[string][]*fakeResult
+	}{
+		{
+			name: "exited",
+			results: map[string][]*fakeResult{
+				"dockerd --config-file": {{stdout: "starting containerd\n", stderr: "failed to start daemon: permission denied\n", code: 1}},
+				"docker version":        {{stderr: "Cannot connect to the Docker daemon\n", code: 1}},
+			},
+		},
+		{
+			name: "timeout",
+			results: map[string][]*fakeResult{
+				"dockerd --config-file": {{stdout: "starting containerd\n", stderr: "failed to start daemon: permission denied\n"}},
+				"docker version":        {{stderr: "Cannot connect to the Docker daemon\n", code: 1}},
+			},
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// setup filesystem
+			appFS = afero.NewMemMapFs()
+
+			// setup types
+			d := &Daemon{
+				Readiness: &Readiness{
+					Timeout:    Duration(50 * time.Millisecond),
+					Backoff:    Duration(time.Millisecond),
+					MaxBackoff: Duration(time.Millisecond),
+				},
+				Runner: newFakeRunner(t, test.results),
+			}
+
+			err := d.Exec(t.Context())
+			if err == nil {
+				t.Fatalf("Exec should have returned err")
+			}
+
+			// verify the output of the daemon is reported with the error
+			for _, want := range []string{"lines of dockerd output", "starting containerd", "failed to start daemon: permission denied"} {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Exec err should contain %q: %v", want, err)
+				}
+			}
+
+			_ = d.Stop()
+		})
+	}
+}
+
+func TestDocker_Daemon_Validate(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		failure bool
+		daemon  *Daemon
+	}{
+		{failure: false, daemon: nil},
+		{failure: false, daemon: &Daemon{}},
+		{failure: false, daemon: &Daemon{Mode: "embedded"}},
+		{failure: false, daemon: &Daemon{Mode: "external", MTU: 1500}},
+		{failure: false, daemon: &Daemon{DataRoot: "/cache/docker", Cache: &Cache{Budget: "20GB"}}},
+		{failure: true, daemon: &Daemon{Mode: "remote"}},
+		{failure: true, daemon: &Daemon{Cache: &Cache{Budget: "lots"}}},
+	}
+
+	// run tests
+	for _, test := range tests {
+		err := test.daemon.Validate()
+
+		if test.failure {
+			if err == nil {
+				t.Errorf("Validate should have returned err for %+v", test.daemon)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Validate returned err: %v", err)
+		}
+	}
+}
+
+func TestDocker_Daemon_Exec_External(t *testing.T) {
+	// setup types
+	t.Setenv("DOCKER_HOST", "tcp://docker.example.com:2376")
+
+	r := newFakeRunner(t, nil)
+
+	d := &Daemon{
+		Mode:   externalMode,
+		Runner: r,
+	}
+
+	err := d.Exec(t.Context())
+	if err != nil {
+		t.Errorf("Exec returned err: %v", err)
+	}
+
+	// verify the daemon is only checked to be ready
+	if got := r.Commands(); len(got) != 1 || got[0] != "docker version" {
+		t.Errorf("Exec commands are %v, want [docker version]", got)
+	}
+}
+
+func TestDocker_Daemon_Exec_External_Error(t *testing.T) {
+	// setup types
+	t.Setenv("DOCKER_HOST", "tcp://docker.example.com:2376")
+
+	r := newFakeRunner(t, map[string][]*fakeResult{
+		"docker version": {{code: 1}},
+	})
+
+	d := &Daemon{
+		Mode:      externalMode,
+		Readiness: &Readiness{Timeout: Duration(20 * time.Millisecond), Backoff: Duration(time.Millisecond)},
+		Runner:    r,
+	}
+
+	err := d.Exec(t.Context())
+	if err == nil || !strings.Contains(err.Error(), "unable to reach docker daemon at tcp://docker.example.com:2376") {
+		t.Errorf("Exec should have returned err for the unreachable daemon: %v", err)
+	}
+
+	for _, c := range r.Commands() {
+		if c != "docker version" {
+			t.Errorf("Exec should not have run %s", c)
+		}
+	}
+}
+
+func TestDocker_Daemon_Exec_InvalidConfig(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	r := newFakeRunner(t, map[string][]*fakeResult{
+		"dockerd --validate": {{stderr: "unable to configure the Docker daemon with file /etc/docker/daemon.json\n", code: 1}},
+	})
+
+	d := &Daemon{
+		Config: map[string]any{"max-concurrent-uploads": "five"},
+		Runner: r,
+	}
+
+	err := d.Exec(t.Context())
+	if err == nil || !strings.Contains(err.Error(), "invalid daemon configuration") {
+		t.Errorf("Exec should have returned err for the invalid configuration: %v", err)
+	}
+
+	// verify the daemon is not started with the invalid configuration
+	if got := r.Commands(); len(got) != 1 {
+		t.Errorf("Exec commands are %v, want only the validation", got)
+	}
+}
+
+func TestDocker_Daemon_Stop(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name    string
+		failure bool
+		result  *fakeResult
+		signals []string
+	}{
+		{
+			name:    "graceful",
+			failure: false,
+			result:  &fakeResult{},
+			signals: []string{"terminated"},
+		},
+		{
+			name:    "exit status",
+			failure: true,
+			result:  &fakeResult{stopCode: 1},
+			signals: []string{"terminated"},
+		},
+		{
+			name:    "killed",
+			failure: true,
+			result:  &fakeResult{ignoreTerm: true},
+			signals: []string{"terminated", "killed"},
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// setup filesystem
+			appFS = afero.NewMemMapFs()
+
+			r := newFakeRunner(t, map[string][]*fakeResult{
+				"dockerd --config-file": {test.result},
+			})
+
+			d := &Daemon{
+				Readiness:   &Readiness{Timeout: Duration(time.Second), Backoff: Duration(time.Millisecond)},
+				Runner:      r,
+				StopTimeout: Duration(10 * time.Millisecond),
+			}
+
+			err := d.Exec(t.Context())
+			if err != nil {
+				t.Errorf("Exec returned err: %v", err)
+			}
+
+			err = d.Stop()
+
+			if test.failure {
+				if err == nil {
+					t.Errorf("Stop should have returned err")
+				}
+			} else if err != nil {
+				t.Errorf("Stop returned err: %v", err)
+			}
+
+			if got := r.Signals(); !reflect.DeepEqual(got, test.signals) {
+				t.Errorf("Stop signals are %v, want %v", got, test.signals)
+			}
+		})
+	}
+}
+
+func TestDocker_Daemon_Stop_NotStarted(t *testing.T) {
+	// setup types
+	r := newFakeRunner(t, nil)
+
+	d := &Daemon{Mode: externalMode, Runner: r}
+
+	err := d.Stop()
+	if err != nil {
+		t.Errorf("Stop returned err: %v", err)
+	}
+
+	if got := r.Signals(); len(got) > 0 {
+		t.Errorf("Stop signals are %v, want none", got)
+	}
+}
@@ -0,0 +1,575 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// bytesUnits represents the multiplier for each unit of a size in bytes.
+var bytesUnits = map[string]float64{
+	"":  1,
+	"k": 1 << 10,
+	"m": 1 << 20,
+	"g": 1 << 30,
+	"t": 1 << 40,
+	"p": 1 << 50,
+}
+
+// Cache represents the management of the data root mounted from the host.
+type Cache struct {
+	// enables setting the maximum size of the data root (e.g. 20GB)
+	Budget string
+	// enables setting the maximum time to wait for the lock on the data root (default 10m)
+	LockTimeout Duration `json:"lock_timeout"`
+}
+
+// danglingImage represents an untagged image in the daemon.
+type danglingImage struct {
+	ID        string
+	CreatedAt string
+	Size      string
+}
+
+const (
+	// dataRootLockFile is the name of the lock file created in the data root.
+	dataRootLockFile = ".vela-docker.lock"
+
+	// defaultLockTimeout is the default maximum time to wait
+	// for the lock on the data root held by another step.
+	defaultLockTimeout = 10 * time.Minute
+
+	// lockBackoff is the time to wait between attempts to lock the data root.
+	lockBackoff = time.Second
+
+	// mountInfoPath is the location of the mounts for the plugin.
+	mountInfoPath = "/proc/self/mountinfo"
+
+	// imageCreatedLayout is the layout of the creation time output for an image.
+	imageCreatedLayout = "2006-01-02 15:04:05 -0700 MST"
+)
+
+// sizeUnits represents the multiplier for each unit of a size output by docker.
+var sizeUnits = map[string]float64{
+	"b":  1,
+	"kb": 1e3,
+	"mb": 1e6,
+	"gb": 1e9,
+	"tb": 1e12,
+	"pb": 1e15,
+}
+
+// Validate verifies the Cache is properly configured.
+func (c *Cache) Validate() error {
+	logrus.Trace("validating daemon cache configuration")
+
+	// check if a budget is provided
+	if len(c.Budget) == 0 {
+		return nil
+	}
+
+	budget, err := ramInBytes(c.Budget)
+	if err != nil {
+		return fmt.Errorf("invalid daemon cache budget: %w", err)
+	}
+
+	// verify the budget is a positive size
+	if budget <= 0 {
+		return fmt.Errorf("invalid daemon cache budget %q: must be greater than 0", c.Budget)
+	}
+
+	return nil
+}
+
+// Lock takes the lock on the data root when it is a volume mounted from
+// the host to prevent concurrent steps on the host from sharing it.
+//
+// The lock is released by Unlock once the daemon stopped.
+func (d *Daemon) Lock(ctx context.Context) error {
+	// check if the cache is managed by the plugin
+	if d.Cache == nil {
+		return nil
+	}
+
+	root := d.dataRoot()
+
+	mounted, err := isMountPoint(root)
+	if err != nil {
+		return fmt.Errorf("unable to check if data root %s is mounted: %w", root, err)
+	}
+
+	// check if the data root is persisted on the host
+	if !mounted {
+		logrus.Warnf("data root %s is not a mounted volume - skipping cache management", root)
+
+		return nil
+	}
+
+	timeout := defaultLockTimeout
+	if d.Cache.LockTimeout > 0 {
+		timeout = time.Duration(d.Cache.LockTimeout)
+	}
+
+	path := filepath.Join(root, dataRootLockFile)
+
+	// the lock is held on a file of the host which is
+	// released by the kernel when the plugin exits
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to open lock file %s: %w", path, err)
+	}
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for attempt := 1; ; attempt++ {
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+
+		// check if the lock is held by another step
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			f.Close()
+
+			return fmt.Errorf("unable to lock data root %s: %w", root, err)
+		}
+
+		// only alert the user once while waiting
+		if attempt == 1 {
+			logrus.Infof("waiting up to %s for data root %s locked by another step", timeout, root)
+		}
+
+		select {
+		case <-ctx.Done():
+			f.Close()
+
+			return fmt.Errorf("unable to lock data root %s: %w", root, context.Cause(ctx))
+		case <-deadline.C:
+			f.Close()
+
+			return fmt.Errorf("unable to lock data root %s: locked by another step after %s", root, timeout)
+		case <-time.After(lockBackoff):
+		}
+	}
+
+	logrus.Infof("locked data root %s for managing the cache", root)
+
+	d.lock = f
+
+	return nil
+}
+
+// Unlock releases the lock on the data root taken by Lock.
+func (d *Daemon) Unlock() {
+	// check if the data root is locked
+	if d.lock == nil {
+		return
+	}
+
+	err := d.lock.Close()
+	if err != nil {
+		logrus.Warnf("unable to release lock on data root %s: %v", d.dataRoot(), err)
+	}
+
+	d.lock = nil
+}
+
+// Prune removes the dangling images and the build cache oldest first
+// until the data root locked by the plugin fits within the budget.
+//
+// The data root is only pruned once the daemon started by the plugin was ready.
+func (d *Daemon) Prune(ctx context.Context) error {
+	// check if the cache is managed by the plugin
+	if d == nil || d.lock == nil || len(d.Cache.Budget) == 0 {
+		return nil
+	}
+
+	// check if the daemon never became ready to accept requests
+	if !d.ready {
+		logrus.Debug("skipping prune of the data root since the docker daemon never became ready")
+
+		return nil
+	}
+
+	// check if the daemon already exited
+	select {
+	case <-d.done:
+		return nil
+	default:
+	}
+
+	budget, err := ramInBytes(d.Cache.Budget)
+	if err != nil {
+		return fmt.Errorf("invalid daemon cache budget: %w", err)
+	}
+
+	root := d.dataRoot()
+
+	// default to running the commands on the host
+	runner := runnerOrExec(d.Runner)
+
+	usage, err := diskUsage(ctx, runner)
+	if err != nil {
+		return err
+	}
+
+	total := sumUsage(usage)
+
+	// check if the data root fits within the budget
+	if total <= budget {
+		logrus.Infof("data root %s uses %s within the %s budget", root, humanSize(total), d.Cache.Budget)
+
+		return nil
+	}
+
+	logrus.Infof("data root %s uses %s exceeding the %s budget - pruning dangling images and build cache", root, humanSize(total), d.Cache.Budget)
+
+	images, err := danglingImages(ctx, runner)
+	if err != nil {
+		return err
+	}
+
+	// remove the dangling images oldest first until the budget is met
+	for _, image := range images {
+		if total <= budget {
+			break
+		}
+
+		err = execCmd(runner, imageRemoveCmd(ctx, image.ID))
+		if err != nil {
+			logrus.Warnf("unable to remove dangling image %s: %v", image.ID, err)
+
+			continue
+		}
+
+		size, _ := sizeInBytes(image.Size)
+
+		total -= size
+	}
+
+	// check if the build cache needs to be pruned
+	if total > budget {
+		usage, err = diskUsage(ctx, runner)
+		if err != nil {
+			return err
+		}
+
+		total = sumUsage(usage)
+
+		// the daemon removes the least recently used build cache
+		// until the build cache fits within the provided storage
+		keep := max(0, usage["Build Cache"]-(total-budget))
+
+		err = execCmd(runner, builderPruneCmd(ctx, keep))
+		if err != nil {
+			return fmt.Errorf("unable to prune build cache: %w", err)
+		}
+	}
+
+	usage, err = diskUsage(ctx, runner)
+	if err != nil {
+		return err
+	}
+
+	total = sumUsage(usage)
+
+	// check if the data root still exceeds the budget
+	if total > budget {
+		logrus.Warnf("data root %s uses %s exceeding the %s budget after pruning", root, humanSize(total), d.Cache.Budget)
+
+		return nil
+	}
+
+	logrus.Infof("data root %s uses %s within the %s budget after pruning", root, humanSize(total), d.Cache.Budget)
+
+	return nil
+}
+
+// dataRoot returns the root directory for the state of the daemon.
+func (d *Daemon) dataRoot() string {
+	// check if DataRoot is provided
+	if len(d.DataRoot) > 0 {
+		return d.DataRoot
+	}
+
+	// check if the data-root key is provided
+	if root, ok := d.Config["data-root"].(string); ok && len(root) > 0 {
+		return root
+	}
+
+	return d.defaultDataRoot()
+}
+
+// isMountPoint returns true when the path is a mount point for the plugin.
+func isMountPoint(path string) (bool, error) {
+	// use custom filesystem which enables us to test
+	a := &afero.Afero{
+		Fs: appFS,
+	}
+
+	data, err := a.ReadFile(mountInfoPath)
+	if err != nil {
+		return false, err
+	}
+
+	// the mount points escape the whitespace and backslash characters
+	unescape := strings.NewReplacer(`\040`, " ", `\011`, "\t", `\012`, "\n", `\134`, `\`)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	for scanner.Scan() {
+		// the mount point is the fifth field of the mount
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+
+		if filepath.Clean(unescape.Replace(fields[4])) == filepath.Clean(path) {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
+
+// diskUsage returns the size of the data root
+// in bytes keyed by the type of the data.
+func diskUsage(ctx context.Context, runner Runner) (map[string]int64, error) {
+	stdout, err := captureCmd(runner, systemDFCmd(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("unable to capture disk usage of the daemon: %w", err)
+	}
+
+	// variable to store the disk usage
+	usage := make(map[string]int64)
+
+	for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		// check if the line is empty
+		if len(line) == 0 {
+			continue
+		}
+
+		// variable to store the disk usage of the type
+		var du struct {
+			Type string
+			Size string
+		}
+
+		err = json.Unmarshal([]byte(line), &du)
+		if err != nil {
+			return nil, fmt.Errorf("unable to unmarshal disk usage of the daemon: %w", err)
+		}
+
+		usage[du.Type], err = sizeInBytes(du.Size)
+		if err != nil {
+			return nil, fmt.Errorf("invalid disk usage for %s: %w", du.Type, err)
+		}
+	}
+
+	return usage, nil
+}
+
+// danglingImages returns the dangling images sorted oldest first.
+func danglingImages(ctx context.Context, runner Runner) ([]*danglingImage, error) {
+	stdout, err := captureCmd(runner, danglingImagesCmd(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("unable to list dangling images: %w", err)
+	}
+
+	// variable to store the dangling images with their creation time
+	var images []*danglingImage
+
+	created := make(map[*danglingImage]time.Time)
+
+	for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		// check if the line is empty
+		if len(line) == 0 {
+			continue
+		}
+
+		image := new(danglingImage)
+
+		err = json.Unmarshal([]byte(line), image)
+		if err != nil {
+			return nil, fmt.Errorf("unable to unmarshal dangling image: %w", err)
+		}
+
+		created[image], err = time.Parse(imageCreatedLayout, image.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid creation time for dangling image %s: %w", image.ID, err)
+		}
+
+		images = append(images, image)
+	}
+
+	slices.SortStableFunc(images, func(a, b *danglingImage) int {
+		return created[a].Compare(created[b])
+	})
+
+	return images, nil
+}
+
+// captureCmd runs the command with the runner and returns the output.
+func captureCmd(runner Runner, cmd *exec.Cmd) (string, error) {
+	// capture the output of the command
+	stdout := new(strings.Builder)
+	stderr := new(strings.Builder)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err := runner.Run(cmd)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
+
+// sumUsage returns the total size of the disk usage.
+func sumUsage(usage map[string]int64) int64 {
+	// variable to store the total size
+	var total int64
+
+	for _, size := range usage {
+		total += size
+	}
+
+	return total
+}
+
+// ramInBytes converts the size (e.g. 512m) to bytes using binary units.
+func ramInBytes(s string) (int64, error) {
+	// check if the size is unlimited
+	if s == "-1" {
+		return -1, nil
+	}
+
+	// verify the size is valid
+	if !bytesRegex.MatchString(s) {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	s = strings.ToLower(strings.ReplaceAll(s, " ", ""))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "b"), "i")
+
+	// capture the unit of the size
+	number := strings.TrimRight(s, "kmgtp")
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", s, err)
+	}
+
+	return int64(math.Round(value * bytesUnits[s[len(number):]])), nil
+}
+
+// sizeInBytes converts the size output by docker (e.g. 1.5GB) to bytes using decimal units.
+func sizeInBytes(s string) (int64, error) {
+	size := strings.ToLower(strings.TrimSpace(s))
+
+	// capture the unit of the size
+	number := strings.TrimRight(size, "kmgtpb")
+
+	multiplier, ok := sizeUnits[size[len(number):]]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", s, err)
+	}
+
+	return int64(value * multiplier), nil
+}
+
+// humanSize converts the bytes to a size (e.g. 1.5GB) using decimal units.
+func humanSize(n int64) string {
+	units := []string{"B", "kB", "MB", "GB", "TB", "PB"}
+
+	value := float64(n)
+
+	// variable to store the index of the unit
+	i := 0
+
+	for value >= 1000 && i < len(units)-1 {
+		value /= 1000
+		i++
+	}
+
+	return fmt.Sprintf("%.4g%s", value, units[i])
+}
+
+// systemDFCmd is a helper function to output
+// the disk usage of the daemon in JSON.
+func systemDFCmd(ctx context.Context) *exec.Cmd {
+	logrus.Trace("creating docker system df command")
+
+	// variable to store flags for command
+	var flags []string
+
+	// add flags for outputting the disk usage in JSON
+	flags = append(flags, "system", "df", "--format", "{{json .}}")
+
+	return exec.CommandContext(ctx, _docker, flags...)
+}
+
+// danglingImagesCmd is a helper function to output
+// the dangling images of the daemon in JSON.
+func danglingImagesCmd(ctx context.Context) *exec.Cmd {
+	logrus.Trace("creating docker image ls command")
+
+	// variable to store flags for command
+	var flags []string
+
+	// add flags for listing the dangling images in JSON
+	flags = append(flags, "image", "ls", "--filter", "dangling=true", "--format", "{{json .}}")
+
+	return exec.CommandContext(ctx, _docker, flags...)
+}
+
+// imageRemoveCmd is a helper function to
+// remove the image from the daemon.
+func imageRemoveCmd(ctx context.Context, id string) *exec.Cmd {
+	logrus.Trace("creating docker image rm command")
+
+	// variable to store flags for command
+	var flags []string
+
+	// add flags for removing the image
+	flags = append(flags, "image", "rm", id)
+
+	return exec.CommandContext(ctx, _docker, flags...)
+}
+
+// builderPruneCmd is a helper function to remove the least
+// recently used build cache exceeding the provided storage.
+func builderPruneCmd(ctx context.Context, keep int64) *exec.Cmd {
+	logrus.Trace("creating docker builder prune command")
+
+	// variable to store flags for command
+	var flags []string
+
+	// add flags for pruning the build cache
+	flags = append(flags, "builder", "prune", "--force", "--keep-storage", strconv.FormatInt(keep, 10))
+
+	return exec.CommandContext(ctx, _docker, flags...)
+}
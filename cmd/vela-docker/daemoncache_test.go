@@ -0,0 +1,356 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// mountDataRoot creates the mounts for the plugin with the data root mounted.
+func mountDataRoot(t *testing.T, root string) {
+	t.Helper()
+
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	mounts := "22 1 0:21 / / rw,relatime - overlay overlay rw\n" +
+		"31 22 259:1 /var/cache/docker " + root + " rw,relatime - ext4 /dev/nvme0n1p1 rw\n"
+
+	err := afero.WriteFile(appFS, mountInfoPath, []byte(mounts), 0644)
+	if err != nil {
+		t.Fatalf("unable to write mounts: %v", err)
+	}
+}
+
+func TestDocker_Cache_Validate(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name    string
+		failure bool
+		cache   *Cache
+	}{
+		{
+			name:    "budget",
+			failure: false,
+			cache:   &Cache{Budget: "20GB"},
+		},
+		{
+			name:    "lock only",
+			failure: false,
+			cache:   &Cache{LockTimeout: Duration(time.Minute)},
+		},
+		{
+			name:    "invalid budget",
+			failure: true,
+			cache:   &Cache{Budget: "lots"},
+		},
+		{
+			name:    "zero budget",
+			failure: true,
+			cache:   &Cache{Budget: "0"},
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.cache.Validate()
+
+			if test.failure {
+				if err == nil {
+					t.Errorf("Validate should have returned err")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Errorf("Validate returned err: %v", err)
+			}
+		})
+	}
+}
+
+func TestDocker_Daemon_Lock(t *testing.T) {
+	// setup types
+	root := t.TempDir()
+
+	mountDataRoot(t, root)
+
+	d := &Daemon{Cache: &Cache{}, DataRoot: root}
+
+	err := d.Lock(t.Context())
+	if err != nil {
+		t.Fatalf("Lock returned err: %v", err)
+	}
+
+	if d.lock == nil {
+		t.Fatalf("Lock should have locked the data root")
+	}
+
+	// verify another step is unable to lock the data root
+	f, err := os.Open(filepath.Join(root, dataRootLockFile))
+	if err != nil {
+		t.Fatalf("unable to open lock file: %v", err)
+	}
+	defer f.Close()
+
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == nil {
+		t.Errorf("Lock should have prevented another lock on the data root")
+	}
+
+	d.Unlock()
+
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err != nil {
+		t.Errorf("Unlock should have released the data root: %v", err)
+	}
+}
+
+func TestDocker_Daemon_Lock_Locked(t *testing.T) {
+	// setup types
+	root := t.TempDir()
+
+	mountDataRoot(t, root)
+
+	other := &Daemon{Cache: &Cache{}, DataRoot: root}
+
+	err := other.Lock(t.Context())
+	if err != nil {
+		t.Fatalf("Lock returned err: %v", err)
+	}
+	defer other.Unlock()
+
+	d := &Daemon{Cache: &Cache{LockTimeout: Duration(10 * time.Millisecond)}, DataRoot: root}
+
+	err = d.Lock(t.Context())
+	if err == nil {
+		t.Errorf("Lock should have returned err")
+	}
+}
+
+func TestDocker_Daemon_Lock_NotMounted(t *testing.T) {
+	// setup types
+	root := t.TempDir()
+
+	mountDataRoot(t, "/var/lib/docker")
+
+	d := &Daemon{Cache: &Cache{}, DataRoot: root}
+
+	err := d.Lock(t.Context())
+	if err != nil {
+		t.Errorf("Lock returned err: %v", err)
+	}
+
+	if d.lock != nil {
+		t.Errorf("Lock should not have locked a data root which is not mounted")
+	}
+
+	if ok, _ := afero.Exists(afero.NewOsFs(), filepath.Join(root, dataRootLockFile)); ok {
+		t.Errorf("Lock should not have created the lock file")
+	}
+}
+
+func TestDocker_Daemon_Prune(t *testing.T) {
+	// setup types
+	df := func(images, cache string) *fakeResult {
+		return &fakeResult{stdout: `{"Size":"` + images + `","Type":"Images"}` + "\n" +
+			`{"Size":"0B","Type":"Containers"}` + "\n" +
+			`{"Size":"0B","Type":"Local Volumes"}` + "\n" +
+			`{"Size":"` + cache + `","Type":"Build Cache"}` + "\n"}
+	}
+
+	dangling := &fakeResult{stdout: `{"CreatedAt":"2025-03-01 10:00:00 +0000 UTC","ID":"newest","Size":"2GB"}` + "\n" +
+		`{"CreatedAt":"2025-01-01 10:00:00 +0000 UTC","ID":"oldest","Size":"2GB"}` + "\n" +
+		`{"CreatedAt":"2025-02-01 10:00:00 +0000 UTC","ID":"older","Size":"2GB"}` + "\n"}
+
+	// setup tests
+	tests := []struct {
+		name    string
+		results map[string][]*fakeResult
+		want    []string
+	}{
+		{
+			name: "within budget",
+			results: map[string][]*fakeResult{
+				"docker system df": {df("4GB", "5GB")},
+			},
+			want: []string{"docker system df --format {{json .}}"},
+		},
+		{
+			name: "dangling images",
+			results: map[string][]*fakeResult{
+				"docker system df": {df("9GB", "5GB"), df("6GB", "4GB")},
+				"docker image ls":  {dangling},
+			},
+			want: []string{
+				"docker system df --format {{json .}}",
+				"docker image ls --filter dangling=true --format {{json .}}",
+				"docker image rm oldest",
+				"docker image rm older",
+				"docker system df --format {{json .}}",
+			},
+		},
+		{
+			name: "build cache",
+			results: map[string][]*fakeResult{
+				"docker system df": {df("14GB", "6GB"), df("8GB", "6GB"), df("8GB", "2GB")},
+				"docker image ls":  {dangling},
+			},
+			want: []string{
+				"docker system df --format {{json .}}",
+				"docker image ls --filter dangling=true --format {{json .}}",
+				"docker image rm oldest",
+				"docker image rm older",
+				"docker image rm newest",
+				"docker system df --format {{json .}}",
+				"docker builder prune --force --keep-storage 2737418240",
+				"docker system df --format {{json .}}",
+			},
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+
+			mountDataRoot(t, root)
+
+			r := newFakeRunner(t, test.results)
+
+			d := &Daemon{Cache: &Cache{Budget: "10GB"}, DataRoot: root, Runner: r, ready: true}
+
+			err := d.Lock(t.Context())
+			if err != nil {
+				t.Fatalf("Lock returned err: %v", err)
+			}
+			defer d.Unlock()
+
+			err = d.Prune(t.Context())
+			if err != nil {
+				t.Errorf("Prune returned err: %v", err)
+			}
+
+			if got := r.Commands(); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Prune commands are %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDocker_Daemon_Prune_NotReady(t *testing.T) {
+	// setup types
+	root := t.TempDir()
+
+	mountDataRoot(t, root)
+
+	r := newFakeRunner(t, nil)
+
+	d := &Daemon{Cache: &Cache{Budget: "10GB"}, DataRoot: root, Runner: r}
+
+	err := d.Lock(t.Context())
+	if err != nil {
+		t.Fatalf("Lock returned err: %v", err)
+	}
+	defer d.Unlock()
+
+	err = d.Prune(t.Context())
+	if err != nil {
+		t.Errorf("Prune returned err: %v", err)
+	}
+
+	if got := r.Commands(); len(got) > 0 {
+		t.Errorf("Prune commands are %v, want none", got)
+	}
+}
+
+func TestDocker_Daemon_Prune_NotLocked(t *testing.T) {
+	// setup types
+	r := newFakeRunner(t, nil)
+
+	d := &Daemon{Cache: &Cache{Budget: "10GB"}, Runner: r}
+
+	err := d.Prune(t.Context())
+	if err != nil {
+		t.Errorf("Prune returned err: %v", err)
+	}
+
+	if got := r.Commands(); len(got) > 0 {
+		t.Errorf("Prune commands are %v, want none", got)
+	}
+}
+
+func TestDocker_ramInBytes(t *testing.T) {
+	// setup tests
+	tests := map[string]int64{
+		"-1":    -1,
+		"1024":  1024,
+		"1k":    1024,
+		"512m":  512 << 20,
+		"1.5GB": 3 << 29,
+		"2gib":  2 << 30,
+	}
+
+	// run tests
+	for s, want := range tests {
+		got, err := ramInBytes(s)
+		if err != nil {
+			t.Errorf("ramInBytes returned err: %v", err)
+		}
+
+		if got != want {
+			t.Errorf("ramInBytes for %s is %d, want %d", s, got, want)
+		}
+	}
+
+	_, err := ramInBytes("1x")
+	if err == nil {
+		t.Errorf("ramInBytes should have returned err")
+	}
+}
+
+func TestDocker_sizeInBytes(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		size    string
+		failure bool
+		want    int64
+	}{
+		{size: "0B", want: 0},
+		{size: "512kB", want: 512000},
+		{size: "1.5GB", want: 1500000000},
+		{size: "2TB", want: 2000000000000},
+		{size: "N/A", failure: true},
+		{size: "1.5XB", failure: true},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got, err := sizeInBytes(test.size)
+
+		if test.failure {
+			if err == nil {
+				t.Errorf("sizeInBytes for %s should have returned err", test.size)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("sizeInBytes for %s returned err: %v", test.size, err)
+		}
+
+		if got != test.want {
+			t.Errorf("sizeInBytes for %s is %d, want %d", test.size, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,421 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+const (
+	// daemonConfigPath is the location of the daemon.json file rendered for the daemon.
+	daemonConfigPath = "/etc/docker/daemon.json"
+
+	// daemonDataRoot is the default root directory for the state of the daemon.
+	daemonDataRoot = "/var/lib/docker"
+)
+
+var (
+	// daemonSettings represents the typed settings of the daemon parameter
+	// which are not captured as daemon.json keys.
+	daemonSettings = []string{
+		"bip",
+		"cache",
+		"data_root",
+		"dns",
+		"experimental",
+		"insecure_registries",
+		"ipv6",
+		"log_level",
+		"mode",
+		"mtu",
+		"readiness",
+		"registry_mirrors",
+		"rootless",
+		"stop_timeout",
+		"storage",
+	}
+
+	// daemonConfigKeys represents the top-level daemon.json keys supported by dockerd.
+	daemonConfigKeys = []string{
+		"allow-direct-routing",
+		"authorization-plugins",
+		"bip",
+		"bip6",
+		"bridge",
+		"builder",
+		"cdi-spec-dirs",
+		"cgroup-parent",
+		"containerd",
+		"containerd-namespace",
+		"containerd-plugins-namespace",
+		"cpu-rt-period",
+		"cpu-rt-runtime",
+		"data-root",
+		"debug",
+		"default-address-pools",
+		"default-cgroupns-mode",
+		"default-gateway",
+		"default-gateway-v6",
+		"default-ipc-mode",
+		"default-network-opts",
+		"default-runtime",
+		"default-shm-size",
+		"default-ulimits",
+		"dns",
+		"dns-opts",
+		"dns-search",
+		"exec-opts",
+		"exec-root",
+		"experimental",
+		"features",
+		"firewall-backend",
+		"fixed-cidr",
+		"fixed-cidr-v6",
+		"group",
+		"host-gateway-ip",
+		"icc",
+		"init",
+		"init-path",
+		"insecure-registries",
+		"ip",
+		"ip-forward",
+		"ip-forward-no-drop",
+		"ip-masq",
+		"ip6tables",
+		"iptables",
+		"ipv6",
+		"labels",
+		"live-restore",
+		"log-driver",
+		"log-format",
+		"log-level",
+		"log-opts",
+		"max-concurrent-downloads",
+		"max-concurrent-uploads",
+		"max-download-attempts",
+		"metrics-addr",
+		"min-api-version",
+		"mtu",
+		"no-new-privileges",
+		"node-generic-resources",
+		"oom-score-adjust",
+		"pidfile",
+		"proxies",
+		"raw-logs",
+		"registry-mirrors",
+		"runtimes",
+		"seccomp-profile",
+		"selinux-enabled",
+		"shutdown-timeout",
+		"storage-driver",
+		"storage-opts",
+		"swarm-default-advertise-addr",
+		"tls",
+		"tlscacert",
+		"tlscert",
+		"tlskey",
+		"tlsverify",
+		"userland-proxy",
+		"userland-proxy-path",
+		"userns-remap",
+	}
+
+	// reservedConfigKeys represents the daemon.json keys managed by the plugin.
+	reservedConfigKeys = []string{"hosts"}
+)
+
+// Unmarshal captures the daemon settings from the provided JSON or YAML object.
+//
+// The keys not matching a typed setting are captured as daemon.json keys
+// where a key containing dots (e.g. builder.gc) sets a nested key.
+//
+// A key matching neither a typed setting nor a daemon.json key is rejected
+// with the closest match suggested.
+func (d *Daemon) Unmarshal(raw string) error {
+	logrus.Trace("unmarshaling daemon settings")
+
+	object, err := paramObject(raw)
+	if err != nil {
+		return err
+	}
+
+	// variable to store the typed settings
+	typed := make(map[string]any)
+
+	// iterate through the keys in a stable order
+	for _, key := range sortedKeys(object) {
+		value := object[key]
+
+		// check if the key is a typed setting
+		//
+		// the dns key is captured as a daemon.json key
+		// when provided as a list of nameservers
+		_, list := value.([]any)
+		if slices.Contains(daemonSettings, strings.ToLower(key)) && !(strings.EqualFold(key, "dns") && list) {
+			typed[key] = value
+
+			continue
+		}
+
+		// check if the key is a daemon.json key
+		name, _, _ := strings.Cut(key, ".")
+		if !slices.Contains(daemonConfigKeys, name) && !slices.Contains(reservedConfigKeys, name) {
+			return unknownKey(key, name, slices.Concat(daemonSettings, daemonConfigKeys))
+		}
+
+		// check if the daemon.json keys are initialized
+		if d.Config == nil {
+			d.Config = make(map[string]any)
+		}
+
+		err = mergeConfig(d.Config, strings.Split(key, "."), value)
+		if err != nil {
+			return err
+		}
+	}
+
+	// verify the nested keys of the typed settings
+	err = checkKeys(reflect.TypeOf(d), typed, "")
+	if err != nil {
+		return err
+	}
+
+	return decodeObject(typed, d)
+}
+
+// Render renders the daemon.json file from the typed settings and the
+// daemon.json keys and verifies the keys are supported by dockerd.
+func (d *Daemon) Render() (map[string]any, error) {
+	config := d.settings()
+
+	// variable to store the daemon.json keys provided for the typed settings
+	typed := make(map[string]bool, len(config))
+	for key := range config {
+		typed[key] = true
+	}
+
+	// iterate through the daemon.json keys in a stable order
+	for _, key := range sortedKeys(d.Config) {
+		// check if the key is managed by the plugin
+		if slices.Contains(reservedConfigKeys, key) {
+			return nil, fmt.Errorf("invalid daemon key %s: managed by the plugin", key)
+		}
+
+		// check if the key is supported by dockerd
+		if !slices.Contains(daemonConfigKeys, key) {
+			return nil, fmt.Errorf("invalid daemon key %s: not a supported daemon.json key", key)
+		}
+
+		// check if the key is already provided by a typed setting
+		if typed[key] {
+			return nil, fmt.Errorf("invalid daemon key %s: conflicts with the typed daemon setting", key)
+		}
+
+		config[key] = d.Config[key]
+	}
+
+	// add the socket the plugin communicates with the daemon on
+	config["hosts"] = []string{d.Host()}
+
+	// check if a root directory is provided
+	if _, ok := config["data-root"]; !ok {
+		config["data-root"] = d.defaultDataRoot()
+	}
+
+	// check if a log level is provided
+	//
+	// this helps to drastically reduce the level of logs
+	// output by the plugin when starting up the docker daemon
+	if _, ok := config["log-level"]; !ok {
+		config["log-level"] = "error"
+	}
+
+	return config, nil
+}
+
+// Write renders and creates the daemon.json file for the daemon.
+func (d *Daemon) Write() error {
+	logrus.Trace("writing daemon.json file for the daemon")
+
+	// use custom filesystem which enables us to test
+	a := &afero.Afero{
+		Fs: appFS,
+	}
+
+	config, err := d.Render()
+	if err != nil {
+		return err
+	}
+
+	out, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := d.configPath()
+
+	logrus.Debugf("rendered %s:\n%s", path, out)
+
+	err = a.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	return a.WriteFile(path, out, 0644)
+}
+
+// settings returns the daemon.json keys for the typed settings.
+func (d *Daemon) settings() map[string]any {
+	// variable to store the daemon.json keys
+	config := make(map[string]any)
+
+	// check if Bip is provided
+	if len(d.Bip) > 0 {
+		config["bip"] = d.Bip
+	}
+
+	// check if DataRoot is provided
+	if len(d.DataRoot) > 0 {
+		config["data-root"] = d.DataRoot
+	}
+
+	// check if DNS is provided
+	if d.DNS != nil {
+		// check if Servers is provided
+		if len(d.DNS.Servers) > 0 {
+			config["dns"] = d.DNS.Servers
+		}
+
+		// check if Searches is provided
+		if len(d.DNS.Searches) > 0 {
+			config["dns-search"] = d.DNS.Searches
+		}
+	}
+
+	// check if Experimental is provided
+	if d.Experimental {
+		config["experimental"] = true
+	}
+
+	// check if InsecureRegistries is provided
+	if len(d.InsecureRegistries) > 0 {
+		config["insecure-registries"] = d.InsecureRegistries
+	}
+
+	// check if IPV6 is provided
+	if d.IPV6 {
+		config["ipv6"] = true
+	}
+
+	// check if LogLevel is provided
+	if len(d.LogLevel) > 0 {
+		config["log-level"] = d.LogLevel
+	}
+
+	// check if MTU is provided
+	if d.MTU > 0 {
+		config["mtu"] = d.MTU
+	}
+
+	// check if RegistryMirrors is provided
+	if len(d.RegistryMirrors) > 0 {
+		config["registry-mirrors"] = d.RegistryMirrors
+	}
+
+	// check if Storage is provided
+	if d.Storage != nil {
+		// check if Driver is provided
+		if len(d.Storage.Driver) > 0 {
+			config["storage-driver"] = d.Storage.Driver
+		}
+
+		// check if Opts is provided
+		if len(d.Storage.Opts) > 0 {
+			config["storage-opts"] = d.Storage.Opts
+		}
+	}
+
+	return config
+}
+
+// validateCmd is a helper function to verify
+// the daemon.json file is accepted by dockerd.
+func (d *Daemon) validateCmd(ctx context.Context) *exec.Cmd {
+	logrus.Trace("creating dockerd validate command")
+
+	// variable to store flags for command
+	var flags []string
+
+	// add flags for validating the daemon.json file
+	flags = append(flags, "--validate", "--config-file", d.configPath())
+
+	return exec.CommandContext(ctx, _dockerd, flags...)
+}
+
+// mergeConfig sets the value for the nested key in the daemon.json keys
+// merging objects provided for the same key.
+func mergeConfig(config map[string]any, key []string, value any) error {
+	name := key[0]
+
+	// check if the key is nested
+	if len(key) > 1 {
+		nested, ok := config[name].(map[string]any)
+		if !ok {
+			// check if the key is already set to a value
+			if _, exists := config[name]; exists {
+				return fmt.Errorf("invalid daemon key %s: %s is not an object", strings.Join(key, "."), name)
+			}
+
+			nested = make(map[string]any)
+			config[name] = nested
+		}
+
+		return mergeConfig(nested, key[1:], value)
+	}
+
+	// check if an object is merged into an existing object
+	if object, ok := value.(map[string]any); ok {
+		if _, exists := config[name].(map[string]any); exists {
+			for _, k := range sortedKeys(object) {
+				err := mergeConfig(config, []string{name, k}, object[k])
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}
+	}
+
+	// check if the key is already set to a value
+	if _, exists := config[name]; exists {
+		return fmt.Errorf("invalid daemon key %s: provided more than once", name)
+	}
+
+	config[name] = value
+
+	return nil
+}
+
+// sortedKeys returns the keys of the map in sorted order.
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	return keys
+}
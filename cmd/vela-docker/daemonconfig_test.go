@@ -0,0 +1,215 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+func TestDocker_Daemon_Unmarshal(t *testing.T) {
+	// setup types
+	d := new(Daemon)
+
+	raw := `{
+		"registry_mirrors": ["mirror.index.docker.io"],
+		"readiness": {"timeout": "1m"},
+		"data_root": "/cache/docker",
+		"cache": {"budget": "20GB", "lock_timeout": "5m"},
+		"default-address-pools": [{"base": "10.10.0.0/16", "size": 24}],
+		"features": {"containerd-snapshotter": true},
+		"max-concurrent-uploads": 10,
+		"builder": {"gc": {"enabled": true}},
+		"builder.gc.defaultKeepStorage": "20GB"
+	}`
+
+	err := d.Unmarshal(raw)
+	if err != nil {
+		t.Fatalf("Unmarshal returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(d.RegistryMirrors, []string{"mirror.index.docker.io"}) {
+		t.Errorf("Unmarshal registry mirrors are %v", d.RegistryMirrors)
+	}
+
+	if d.Readiness == nil || d.Readiness.Timeout != Duration(60e9) {
+		t.Errorf("Unmarshal readiness is %v", d.Readiness)
+	}
+
+	if d.DataRoot != "/cache/docker" {
+		t.Errorf("Unmarshal data root is %s", d.DataRoot)
+	}
+
+	if d.Cache == nil || d.Cache.Budget != "20GB" || d.Cache.LockTimeout != Duration(5*time.Minute) {
+		t.Errorf("Unmarshal cache is %v", d.Cache)
+	}
+
+	got, _ := json.Marshal(d.Config)
+
+	want := `{"builder":{"gc":{"defaultKeepStorage":"20GB","enabled":true}},"default-address-pools":[{"base":"10.10.0.0/16","size":24}],"features":{"containerd-snapshotter":true},"max-concurrent-uploads":10}`
+
+	if string(got) != want {
+		t.Errorf("Unmarshal config is %s, want %s", got, want)
+	}
+}
+
+func TestDocker_Daemon_Unmarshal_YAML(t *testing.T) {
+	// setup types
+	d := new(Daemon)
+
+	raw := `
+registry_mirrors:
+  - mirror.index.docker.io
+dns:
+  servers: [10.20.1.2]
+max-concurrent-uploads: 10
+`
+
+	err := d.Unmarshal(raw)
+	if err != nil {
+		t.Fatalf("Unmarshal returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(d.RegistryMirrors, []string{"mirror.index.docker.io"}) || d.DNS == nil || d.DNS.Servers[0] != "10.20.1.2" {
+		t.Errorf("Unmarshal typed settings are %+v", d)
+	}
+
+	if d.Config["max-concurrent-uploads"] != 10 {
+		t.Errorf("Unmarshal config is %v", d.Config)
+	}
+}
+
+func TestDocker_Daemon_Unmarshal_Error(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{raw: `["registry_mirrors"]`, want: "must be a JSON or YAML object"},
+		{raw: `{"builder": true, "builder.gc.enabled": true}`, want: "builder is not an object"},
+		{raw: `{"mtu": "1500"}`, want: "cannot unmarshal string"},
+		{raw: `{"registry_mirror": ["mirror.gcr.io"]}`, want: `unknown key "registry_mirror" (did you mean "registry_mirrors"?)`},
+		{raw: `{"max-concurent-uploads": 10}`, want: `unknown key "max-concurent-uploads" (did you mean "max-concurrent-uploads"?)`},
+		{raw: `{"readiness": {"timout": "1m"}}`, want: `unknown key "readiness.timout" (did you mean "timeout"?)`},
+		{raw: `{"buildr.gc.enabled": true}`, want: `unknown key "buildr.gc.enabled" (did you mean "builder"?)`},
+	}
+
+	// run tests
+	for _, test := range tests {
+		err := new(Daemon).Unmarshal(test.raw)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("Unmarshal should have returned err containing %q: %v", test.want, err)
+		}
+	}
+}
+
+func TestDocker_Daemon_Render(t *testing.T) {
+	// setup types
+	d := &Daemon{
+		Bip:      "192.168.1.5/24",
+		DataRoot: "/cache/docker",
+		DNS: &DNS{
+			Servers:  []string{"10.20.1.2", "10.20.1.3"},
+			Searches: []string{"example.com"},
+		},
+		Experimental:       true,
+		InsecureRegistries: []string{"private.registry.com"},
+		IPV6:               true,
+		MTU:                1500,
+		RegistryMirrors:    []string{"mirror.registry.com"},
+		Storage: &Storage{
+			Driver: "overlay2",
+			Opts:   []string{"overlay2.size=10G"},
+		},
+		Config: map[string]any{
+			"max-concurrent-uploads": 10,
+		},
+	}
+
+	want := map[string]any{
+		"bip":                    "192.168.1.5/24",
+		"data-root":              "/cache/docker",
+		"dns":                    []string{"10.20.1.2", "10.20.1.3"},
+		"dns-search":             []string{"example.com"},
+		"experimental":           true,
+		"hosts":                  []string{defaultDockerHost},
+		"insecure-registries":    []string{"private.registry.com"},
+		"ipv6":                   true,
+		"log-level":              "error",
+		"max-concurrent-uploads": 10,
+		"mtu":                    1500,
+		"registry-mirrors":       []string{"mirror.registry.com"},
+		"storage-driver":         "overlay2",
+		"storage-opts":           []string{"overlay2.size=10G"},
+	}
+
+	got, err := d.Render()
+	if err != nil {
+		t.Fatalf("Render returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Render is %v, want %v", got, want)
+	}
+}
+
+func TestDocker_Daemon_Render_Error(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		daemon *Daemon
+		want   string
+	}{
+		{daemon: &Daemon{Config: map[string]any{"hosts": []string{"tcp://0.0.0.0:2375"}}}, want: "managed by the plugin"},
+		{daemon: &Daemon{Config: map[string]any{"max-concurent-uploads": 10}}, want: "not a supported daemon.json key"},
+		{daemon: &Daemon{MTU: 1500, Config: map[string]any{"mtu": 1400}}, want: "conflicts with the typed daemon setting"},
+		{daemon: &Daemon{DataRoot: "/cache/docker", Config: map[string]any{"data-root": "/vela/docker"}}, want: "conflicts with the typed daemon setting"},
+	}
+
+	// run tests
+	for _, test := range tests {
+		_, err := test.daemon.Render()
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("Render should have returned err containing %q: %v", test.want, err)
+		}
+	}
+}
+
+func TestDocker_Daemon_Write(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	d := &Daemon{
+		LogLevel: "debug",
+		Config: map[string]any{
+			"data-root": "/vela/docker",
+		},
+	}
+
+	err := d.Write()
+	if err != nil {
+		t.Fatalf("Write returned err: %v", err)
+	}
+
+	got, err := afero.ReadFile(appFS, daemonConfigPath)
+	if err != nil {
+		t.Fatalf("ReadFile returned err: %v", err)
+	}
+
+	want := `{
+  "data-root": "/vela/docker",
+  "hosts": [
+    "unix:///var/run/docker.sock"
+  ],
+  "log-level": "debug"
+}`
+
+	if string(got) != want {
+		t.Errorf("Write is %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,217 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// userNamespaceSysctls represents the kernel settings
+// preventing a user from creating user namespaces.
+var userNamespaceSysctls = []struct {
+	// location of the setting for the plugin
+	path string
+	// value of the setting which prevents user namespaces
+	disabled string
+	// guidance for enabling user namespaces
+	guidance string
+}{
+	{
+		path:     "/proc/sys/user/max_user_namespaces",
+		disabled: "0",
+		guidance: "user namespaces are disabled - set the sysctl user.max_user_namespaces to a positive value (e.g. 28633) on the worker",
+	},
+	{
+		path:     "/proc/sys/kernel/unprivileged_userns_clone",
+		disabled: "0",
+		guidance: "unprivileged user namespaces are disabled - set the sysctl kernel.unprivileged_userns_clone=1 on the worker",
+	},
+	{
+		path:     "/proc/sys/kernel/apparmor_restrict_unprivileged_userns",
+		disabled: "1",
+		guidance: "AppArmor restricts unprivileged user namespaces - set the sysctl kernel.apparmor_restrict_unprivileged_userns=0 on the worker",
+	},
+}
+
+// Host returns the address the daemon started by the plugin listens on.
+func (d *Daemon) Host() string {
+	// check if the daemon runs without root privileges
+	if d.rootless() {
+		return "unix://" + filepath.Join(runtimeDir(), "docker.sock")
+	}
+
+	return defaultDockerHost
+}
+
+// rootless returns true when the daemon started by the plugin runs without root privileges.
+func (d *Daemon) rootless() bool {
+	return d != nil && d.Rootless && !d.External()
+}
+
+// configPath returns the location of the daemon.json file rendered for the daemon.
+func (d *Daemon) configPath() string {
+	// check if the daemon runs without root privileges
+	if !d.rootless() {
+		return daemonConfigPath
+	}
+
+	return filepath.Join(xdgDir("XDG_CONFIG_HOME", ".config"), "docker", "daemon.json")
+}
+
+// defaultDataRoot returns the default root directory for the state of the daemon.
+func (d *Daemon) defaultDataRoot() string {
+	// check if the daemon runs without root privileges
+	if !d.rootless() {
+		return daemonDataRoot
+	}
+
+	return filepath.Join(xdgDir("XDG_DATA_HOME", filepath.Join(".local", "share")), "docker")
+}
+
+// checkRootless verifies the environment supports running
+// the daemon without root privileges for the provided user.
+//
+// Every unsupported requirement is returned with guidance for resolving it.
+func checkRootless(uid int, name string) error {
+	logrus.Trace("checking support for rootless docker daemon")
+
+	// use custom filesystem which enables us to test
+	a := &afero.Afero{
+		Fs: appFS,
+	}
+
+	// variable to store the unsupported requirements
+	var errs []error
+
+	// check if the plugin runs as root
+	if uid == 0 {
+		errs = append(errs, errors.New("the step runs as root - run the step as a non-root user with subordinate ids (e.g. use the target/vela-docker:rootless image)"))
+	}
+
+	// check if the kernel settings prevent user namespaces
+	for _, sysctl := range userNamespaceSysctls {
+		value, err := a.ReadFile(sysctl.path)
+		if err != nil {
+			// the setting is not provided by every kernel
+			continue
+		}
+
+		if strings.TrimSpace(string(value)) == sysctl.disabled {
+			errs = append(errs, errors.New(sysctl.guidance))
+		}
+	}
+
+	// check if the executables for the daemon are installed
+	for _, bin := range []string{_dockerdRootless, "rootlesskit", "newuidmap", "newgidmap"} {
+		_, err := exec.LookPath(bin)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s not found - use an image with the rootless extras and uidmap installed (e.g. target/vela-docker:rootless)", filepath.Base(bin)))
+		}
+	}
+
+	// check if the user has subordinate ids to map into the user namespace
+	if uid != 0 {
+		for _, path := range []string{"/etc/subuid", "/etc/subgid"} {
+			ok, err := hasSubordinateIDs(a, path, uid, name)
+			if err != nil || !ok {
+				errs = append(errs, fmt.Errorf("no subordinate ids for user %s in %s - add an entry for the user (e.g. %s:100000:65536)", userName(uid, name), path, userName(uid, name)))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// hasSubordinateIDs returns true when the file
+// contains an entry for the provided user.
+func hasSubordinateIDs(a *afero.Afero, path string, uid int, name string) (bool, error) {
+	data, err := a.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	for scanner.Scan() {
+		// the entries are in the format user:start:count
+		owner, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+
+		if owner == strconv.Itoa(uid) || (len(name) > 0 && owner == name) {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
+
+// currentUser returns the id and name of the user the plugin runs as.
+func currentUser() (int, string) {
+	uid := os.Geteuid()
+
+	// the name is unknown for an id without an entry in /etc/passwd
+	u, err := user.LookupId(strconv.Itoa(uid))
+	if err != nil {
+		return uid, ""
+	}
+
+	return uid, u.Username
+}
+
+// userName returns the name of the user or the id when the name is unknown.
+func userName(uid int, name string) string {
+	if len(name) == 0 {
+		return strconv.Itoa(uid)
+	}
+
+	return name
+}
+
+// runtimeDir returns the directory for the socket of the rootless daemon.
+func runtimeDir() string {
+	// check if the runtime directory is provided
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); len(dir) > 0 {
+		return dir
+	}
+
+	return filepath.Join("/run/user", strconv.Itoa(os.Geteuid()))
+}
+
+// xdgDir returns the directory from the environment variable
+// or the provided directory within the home of the user.
+func xdgDir(env, fallback string) string {
+	// check if the directory is provided
+	if dir := os.Getenv(env); len(dir) > 0 {
+		return dir
+	}
+
+	return filepath.Join(homeDir(), fallback)
+}
+
+// homeDir returns the home directory of the user the plugin runs as.
+//
+// The home directory is looked up for the user when HOME is not set like the docker CLI.
+func homeDir() string {
+	// check if the home directory is provided
+	if home, err := os.UserHomeDir(); err == nil {
+		return home
+	}
+
+	u, err := user.LookupId(strconv.Itoa(os.Geteuid()))
+	if err != nil || len(u.HomeDir) == 0 {
+		return "/"
+	}
+
+	return u.HomeDir
+}
@@ -0,0 +1,194 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+// installRootless creates the executables for the rootless daemon
+// and restores the script for the daemon once the test completes.
+func installRootless(t *testing.T, bins ...string) {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	for _, bin := range bins {
+		//nolint:gosec // the executables must be executable for the test
+		err := os.WriteFile(filepath.Join(dir, bin), []byte("#!/bin/sh\n"), 0755)
+		if err != nil {
+			t.Fatalf("unable to write %s: %v", bin, err)
+		}
+	}
+
+	script := _dockerdRootless
+
+	t.Cleanup(func() {
+		_dockerdRootless = script
+	})
+
+	_dockerdRootless = filepath.Join(dir, "dockerd-rootless.sh")
+
+	t.Setenv("PATH", dir)
+}
+
+func TestDocker_Daemon_Host(t *testing.T) {
+	// setup types
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+
+	// setup tests
+	tests := []struct {
+		daemon *Daemon
+		want   string
+	}{
+		{daemon: &Daemon{}, want: defaultDockerHost},
+		{daemon: &Daemon{Rootless: true}, want: "unix:///run/user/1000/docker.sock"},
+		{daemon: &Daemon{Mode: externalMode, Rootless: true}, want: defaultDockerHost},
+	}
+
+	// run tests
+	for _, test := range tests {
+		if got := test.daemon.Host(); got != test.want {
+			t.Errorf("Host is %s, want %s", got, test.want)
+		}
+	}
+}
+
+func TestDocker_Daemon_Command_Rootless(t *testing.T) {
+	// setup types
+	t.Setenv("XDG_CONFIG_HOME", "/home/rootless/.config")
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+
+	d := &Daemon{Rootless: true}
+
+	got := d.Command(t.Context())
+
+	want := []string{_dockerdRootless, "--config-file", "/home/rootless/.config/docker/daemon.json"}
+
+	if !reflect.DeepEqual(got.Args, want) {
+		t.Errorf("Command is %v, want %v", got.Args, want)
+	}
+
+	if !slices.Contains(got.Env, "XDG_RUNTIME_DIR=/run/user/1000") {
+		t.Errorf("Command should set the runtime directory: %v", got.Env)
+	}
+}
+
+func TestDocker_Daemon_Render_Rootless(t *testing.T) {
+	// setup types
+	t.Setenv("XDG_DATA_HOME", "/home/rootless/.local/share")
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+
+	d := &Daemon{Rootless: true}
+
+	got, err := d.Render()
+	if err != nil {
+		t.Fatalf("Render returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(got["hosts"], []string{"unix:///run/user/1000/docker.sock"}) {
+		t.Errorf("Render hosts are %v", got["hosts"])
+	}
+
+	if got["data-root"] != "/home/rootless/.local/share/docker" {
+		t.Errorf("Render data root is %v", got["data-root"])
+	}
+}
+
+func TestDocker_checkRootless(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name    string
+		failure bool
+		uid     int
+		bins    []string
+		files   map[string]string
+		want    []string
+	}{
+		{
+			name:    "supported",
+			failure: false,
+			uid:     1000,
+			bins:    []string{"dockerd-rootless.sh", "rootlesskit", "newuidmap", "newgidmap"},
+			files: map[string]string{
+				"/proc/sys/user/max_user_namespaces": "28633\n",
+				"/etc/subuid":                        "rootless:100000:65536\n",
+				"/etc/subgid":                        "1000:100000:65536\n",
+			},
+		},
+		{
+			name:    "root",
+			failure: true,
+			uid:     0,
+			bins:    []string{"dockerd-rootless.sh", "rootlesskit", "newuidmap", "newgidmap"},
+			want:    []string{"runs as root"},
+		},
+		{
+			name:    "user namespaces disabled",
+			failure: true,
+			uid:     1000,
+			bins:    []string{"dockerd-rootless.sh", "rootlesskit", "newuidmap", "newgidmap"},
+			files: map[string]string{
+				"/proc/sys/user/max_user_namespaces":                     "0\n",
+				"/proc/sys/kernel/apparmor_restrict_unprivileged_userns": "1\n",
+				"/etc/subuid": "rootless:100000:65536\n",
+				"/etc/subgid": "rootless:100000:65536\n",
+			},
+			want: []string{"user.max_user_namespaces", "kernel.apparmor_restrict_unprivileged_userns=0"},
+		},
+		{
+			name:    "missing executables and subordinate ids",
+			failure: true,
+			uid:     1000,
+			bins:    []string{"dockerd-rootless.sh"},
+			files: map[string]string{
+				"/etc/subuid": "octocat:100000:65536\n",
+			},
+			want: []string{"rootlesskit not found", "newuidmap not found", "newgidmap not found", "/etc/subuid", "/etc/subgid"},
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// setup filesystem
+			appFS = afero.NewMemMapFs()
+
+			for path, content := range test.files {
+				err := afero.WriteFile(appFS, path, []byte(content), 0644)
+				if err != nil {
+					t.Fatalf("unable to write %s: %v", path, err)
+				}
+			}
+
+			installRootless(t, test.bins...)
+
+			err := checkRootless(test.uid, "rootless")
+
+			if test.failure {
+				if err == nil {
+					t.Fatalf("checkRootless should have returned err")
+				}
+
+				for _, want := range test.want {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("checkRootless err should contain %q: %v", want, err)
+					}
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Errorf("checkRootless returned err: %v", err)
+			}
+		})
+	}
+}
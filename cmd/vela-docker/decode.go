@@ -0,0 +1,265 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// paramError represents an invalid object provided for a parameter.
+type paramError struct {
+	// name of the parameter
+	Name string
+	// name of the flag for the parameter
+	Flag string
+	// source the parameter was provided from
+	Source string
+	// reason the parameter is invalid
+	Err error
+}
+
+// Error returns the parameter and source with the reason it is invalid.
+func (e *paramError) Error() string {
+	// check if the source of the parameter is known
+	if len(e.Source) == 0 {
+		return fmt.Sprintf("invalid %s parameter: %v", e.Name, e.Err)
+	}
+
+	return fmt.Sprintf("invalid %s parameter from %s: %v", e.Name, e.Source, e.Err)
+}
+
+// Unwrap returns the reason the parameter is invalid.
+func (e *paramError) Unwrap() error {
+	return e.Err
+}
+
+// decodeParam decodes the JSON or YAML object provided for the parameter
+// into v and rejects the keys which do not match a field of v.
+func decodeParam(name, flag, raw string, v any) error {
+	object, err := paramObject(raw)
+	if err != nil {
+		return &paramError{Name: name, Flag: flag, Err: err}
+	}
+
+	err = checkKeys(reflect.TypeOf(v), object, "")
+	if err != nil {
+		return &paramError{Name: name, Flag: flag, Err: err}
+	}
+
+	err = decodeObject(object, v)
+	if err != nil {
+		return &paramError{Name: name, Flag: flag, Err: err}
+	}
+
+	return nil
+}
+
+// paramObject parses the JSON or YAML object provided for a parameter.
+func paramObject(raw string) (map[string]any, error) {
+	// variable to store the parsed object
+	var object map[string]any
+
+	// check if the object is provided as JSON
+	if json.Valid([]byte(raw)) {
+		decoder := json.NewDecoder(strings.NewReader(raw))
+
+		// preserve the precision of numbers in the object
+		decoder.UseNumber()
+
+		err := decoder.Decode(&object)
+		if err != nil {
+			return nil, fmt.Errorf("must be a JSON or YAML object: %w", err)
+		}
+
+		return object, nil
+	}
+
+	err := yaml.Unmarshal([]byte(raw), &object)
+	if err != nil {
+		return nil, fmt.Errorf("must be a JSON or YAML object: %w", err)
+	}
+
+	return object, nil
+}
+
+// decodeObject decodes the parsed object into v.
+func decodeObject(object map[string]any, v any) error {
+	data, err := json.Marshal(object)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// checkKeys verifies every key of the object matches a field of the
+// type and suggests the closest field for the keys that do not.
+func checkKeys(t reflect.Type, object map[string]any, prefix string) error {
+	fields := jsonFields(t)
+
+	// variable to store the names of the fields
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+
+	// iterate through the keys in a stable order
+	for _, key := range sortedKeys(object) {
+		field, ok := fields[strings.ToLower(key)]
+		if !ok {
+			return unknownKey(prefix+key, key, names)
+		}
+
+		// check if the key is a nested object
+		nested, ok := object[key].(map[string]any)
+		if ok && field.Kind() == reflect.Struct {
+			err := checkKeys(field, nested, prefix+key+".")
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// jsonFields returns the types of the fields decoded from
+// a JSON object keyed by the lower case name of the key.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	// check if the type is a pointer
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	fields := make(map[string]reflect.Type)
+
+	for i := range t.NumField() {
+		f := t.Field(i)
+
+		// check if the field is decoded from JSON
+		tag := f.Tag.Get("json")
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+		if len(name) == 0 {
+			name = f.Name
+		}
+
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+
+		fields[strings.ToLower(name)] = ft
+	}
+
+	return fields
+}
+
+// unknownKey formats the error for a key which does
+// not match a field with the closest match suggested.
+func unknownKey(path, key string, candidates []string) error {
+	// check if a close match is found
+	if match := closestMatch(key, candidates); len(match) > 0 {
+		return fmt.Errorf("unknown key %q (did you mean %q?)", path, match)
+	}
+
+	return fmt.Errorf("unknown key %q", path)
+}
+
+// closestMatch returns the candidate closest to the key or
+// an empty string when no candidate is close enough.
+func closestMatch(key string, candidates []string) string {
+	key = strings.ToLower(key)
+
+	// variables to store the closest candidate
+	match, best := "", -1
+
+	for _, c := range candidates {
+		d := editDistance(key, strings.ToLower(c))
+
+		// check if the candidate is closer than the previous ones
+		if best < 0 || d < best || (d == best && c < match) {
+			match, best = c, d
+		}
+	}
+
+	// only suggest candidates differing in a few characters
+	if best < 0 || best > max(2, len(key)/3) {
+		return ""
+	}
+
+	return match
+}
+
+// editDistance returns the Levenshtein distance between the strings.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
+
+// withParamSource adds the source the parameter was provided
+// from to the error for a parameter with an invalid object.
+//
+// Every error for a parameter is annotated when the
+// errors for multiple parameters are joined together.
+func withParamSource(c *cli.Command, err error) error {
+	switch e := err.(type) {
+	case *paramError:
+		e.Source = flagSource(c, e.Flag)
+	case interface{ Unwrap() []error }:
+		// iterate through the joined errors
+		for _, err := range e.Unwrap() {
+			withParamSource(c, err)
+		}
+	}
+
+	return err
+}
+
+// flagSource returns the environment variable or file
+// the value of the string flag was provided from.
+func flagSource(c *cli.Command, name string) string {
+	for _, f := range c.Flags {
+		sf, ok := f.(*cli.StringFlag)
+		if !ok || sf.Name != name {
+			continue
+		}
+
+		// check if the value was provided by a source of the flag
+		if _, source, ok := sf.Sources.LookupWithSource(); ok {
+			return source.String()
+		}
+	}
+
+	return "flag --" + name
+}
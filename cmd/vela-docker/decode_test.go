@@ -0,0 +1,172 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v3"
+)
+
+func TestDocker_decodeParam(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		failure bool
+		raw     string
+		want    *CPU
+		err     string
+	}{
+		{
+			failure: false,
+			raw:     `{"period": 100, "set_cpus": "0-3"}`,
+			want:    &CPU{Period: 100, SetCpus: "0-3"},
+		},
+		{
+			failure: false,
+			raw:     "period: 100\nSet_Cpus: 0-3\n",
+			want:    &CPU{Period: 100, SetCpus: "0-3"},
+		},
+		{
+			failure: true,
+			raw:     `{"setcpus": "0-3"}`,
+			err:     `invalid cpu parameter: unknown key "setcpus" (did you mean "set_cpus"?)`,
+		},
+		{
+			failure: true,
+			raw:     `{"cores": 4}`,
+			err:     `invalid cpu parameter: unknown key "cores"`,
+		},
+		{
+			failure: true,
+			raw:     `{"period": "100"}`,
+			err:     "cannot unmarshal string",
+		},
+		{
+			failure: true,
+			raw:     "- period",
+			err:     "must be a JSON or YAML object",
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got := new(CPU)
+
+		err := decodeParam("cpu", "build.cpu", test.raw, got)
+
+		if test.failure {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("decodeParam should have returned err containing %q: %v", test.err, err)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("decodeParam returned err: %v", err)
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("decodeParam is %+v, want %+v", got, test.want)
+		}
+	}
+}
+
+func TestDocker_closestMatch(t *testing.T) {
+	// setup types
+	candidates := []string{"registry_mirrors", "insecure_registries", "readiness", "storage"}
+
+	// setup tests
+	tests := map[string]string{
+		"registry_mirror":   "registry_mirrors",
+		"Registry-Mirrors":  "registry_mirrors",
+		"insecure_registry": "insecure_registries",
+		"storag":            "storage",
+		"mirrors":           "",
+		"foo":               "",
+	}
+
+	// run tests
+	for key, want := range tests {
+		if got := closestMatch(key, candidates); got != want {
+			t.Errorf("closestMatch for %s is %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestDocker_withParamSource(t *testing.T) {
+	// setup types
+	path := filepath.Join(t.TempDir(), "daemon")
+
+	err := os.WriteFile(path, []byte(`{"mtu": 1500}`), 0600)
+	if err != nil {
+		t.Fatalf("WriteFile returned err: %v", err)
+	}
+
+	c := &cli.Command{
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "daemon",
+				Sources: cli.NewValueSourceChain(cli.EnvVar("PARAMETER_DAEMON"), cli.File(path)),
+			},
+		},
+	}
+
+	// setup tests
+	tests := []struct {
+		env  string
+		want string
+	}{
+		{env: `{"registry_mirror": "mirror.gcr.io"}`, want: `invalid daemon parameter from environment variable "PARAMETER_DAEMON": unknown key`},
+		{env: "", want: `invalid daemon parameter from file "` + path + `": unknown key`},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Setenv("PARAMETER_DAEMON", test.env)
+
+		if len(test.env) == 0 {
+			os.Unsetenv("PARAMETER_DAEMON")
+		}
+
+		err := withParamSource(c, &paramError{Name: "daemon", Flag: "daemon", Err: errors.New(`unknown key "registry_mirror"`)})
+		if err == nil || !strings.HasPrefix(err.Error(), test.want) {
+			t.Errorf("withParamSource is %v, want %s", err, test.want)
+		}
+	}
+
+	// verify the source of every joined error for a parameter is added
+	t.Setenv("PARAMETER_DAEMON", `{"registry_mirror": "mirror.gcr.io"}`)
+	t.Setenv("PARAMETER_CPU", `{"period": "fast"}`)
+
+	c.Flags = append(c.Flags, &cli.StringFlag{
+		Name:    "build.cpu",
+		Sources: cli.NewValueSourceChain(cli.EnvVar("PARAMETER_CPU")),
+	})
+
+	// the errors for the build are joined before the errors for the plugin
+	err = withParamSource(c, errors.Join(
+		&paramError{Name: "daemon", Flag: "daemon", Err: errors.New(`unknown key "registry_mirror"`)},
+		errors.Join(decodeParam("cpu", "build.cpu", `{"period": "fast"}`, new(CPU))),
+	))
+
+	for _, want := range []string{
+		`invalid daemon parameter from environment variable "PARAMETER_DAEMON"`,
+		`invalid cpu parameter from environment variable "PARAMETER_CPU"`,
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("withParamSource is %v, want %s", err, want)
+		}
+	}
+
+	// verify the source of errors for other parameters is not changed
+	err = withParamSource(c, errors.New("invalid tag"))
+	if err.Error() != "invalid tag" {
+		t.Errorf("withParamSource is %v, want invalid tag", err)
+	}
+}
@@ -0,0 +1,213 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Engine represents a backend for running actions against the Docker daemon.
+type Engine interface {
+	// Version outputs the client and server version information.
+	Version(ctx context.Context) error
+	// Info outputs the information for the daemon.
+	Info(ctx context.Context) error
+	// Login authenticates with the registry using the provided retry settings.
+	Login(ctx context.Context, r *Registry, retry *Retry) error
+	// Build builds the image from the provided configuration.
+	Build(ctx context.Context, b *Build) error
+	// Push pushes the tag of the image with the output written to
+	// the provided writers and captures the digest of the image.
+	Push(ctx context.Context, p *Push, stdout, stderr io.Writer) error
+	// Inspect returns the details of the image in the daemon.
+	Inspect(ctx context.Context, ref string) (*ImageInspect, error)
+}
+
+// ImageInspect represents the details of an image in the daemon.
+type ImageInspect struct {
+	ID           string `json:"Id"`
+	RepoDigests  []string
+	Size         int64
+	Os           string
+	Architecture string
+	Variant      string
+}
+
+// engineOrCLI returns the provided engine or
+// the CLI engine when no engine is provided.
+func engineOrCLI(e Engine) Engine {
+	if e == nil {
+		return new(cliEngine)
+	}
+
+	return e
+}
+
+// Platform returns the platform of the image in the format os/arch[/variant].
+func (i *ImageInspect) Platform() string {
+	// check if the platform is provided
+	if len(i.Os) == 0 || len(i.Architecture) == 0 {
+		return ""
+	}
+
+	platform := i.Os + "/" + i.Architecture
+
+	// check if a variant is provided
+	if len(i.Variant) > 0 {
+		platform += "/" + i.Variant
+	}
+
+	return platform
+}
+
+// cliEngine runs the actions against the daemon with the Docker CLI.
+type cliEngine struct {
+	// runner for the commands of the Docker CLI
+	runner Runner
+}
+
+// Version outputs the client and server version information.
+func (e *cliEngine) Version(ctx context.Context) error {
+	return execCmd(e.runner, versionCmd(ctx))
+}
+
+// Info outputs the information for the daemon.
+func (e *cliEngine) Info(ctx context.Context) error {
+	return execCmd(e.runner, infoCmd(ctx))
+}
+
+// Login authenticates with the registry using the provided retry settings.
+func (e *cliEngine) Login(ctx context.Context, r *Registry, retry *Retry) error {
+	return retry.Do(ctx, e.runner, "login to "+r.Name, func() *exec.Cmd {
+		return r.Command(ctx)
+	})
+}
+
+// Build builds the image from the provided configuration.
+func (e *cliEngine) Build(ctx context.Context, b *Build) error {
+	// check if the build runs with buildx
+	if b.Buildx() {
+		// capture a name for the builder of the step
+		builder := buildxBuilderName()
+
+		// create the builder inside the daemon
+		err := execCmd(e.runner, buildxCreateCmd(ctx, builder))
+		if err != nil {
+			return err
+		}
+
+		// remove the builder from the daemon after the build
+		//
+		// the builder is removed even when the build is canceled
+		defer func() {
+			err := execCmd(e.runner, buildxRmCmd(context.WithoutCancel(ctx), builder))
+			if err != nil {
+				logrus.Warnf("unable to remove builder %s: %v", builder, err)
+			}
+		}()
+
+		// bootstrap the builder inside the daemon
+		err = execCmd(e.runner, buildxInspectCmd(ctx, builder))
+		if err != nil {
+			return err
+		}
+	}
+
+	// remove the files for the Vela secrets after the build
+	defer func() {
+		for _, secret := range b.Secrets {
+			secret.remove()
+		}
+	}()
+
+	// write the Vela secrets to files for the build
+	for _, secret := range b.Secrets {
+		err := secret.write()
+		if err != nil {
+			return err
+		}
+	}
+
+	// check if the image is published from the builder
+	if b.Publish {
+		// retry the build when publishing fails on a transient registry error
+		return b.Retry.Run(ctx, "publish "+strings.Join(b.Tags, ", "), func() (string, error) {
+			cmd := b.Command(ctx)
+
+			// capture the end of the build output to classify errors
+			stderr := newTailWriter(retryTailLines)
+			cmd.Stderr = io.MultiWriter(runnerOrExec(e.runner).Stderr(), stderr)
+
+			err := execCmd(e.runner, cmd)
+
+			return buildxPushError(stderr.String()), err
+		})
+	}
+
+	// run the build command for the file
+	return execCmd(e.runner, b.Command(ctx))
+}
+
+// Push pushes the tag of the image with the output written to
+// the provided writers and captures the digest of the image.
+func (e *cliEngine) Push(ctx context.Context, p *Push, stdout, stderr io.Writer) error {
+	// variable to store the end of the push output for the digest
+	var out *tailWriter
+
+	// run the push command for the file
+	err := p.Retry.Do(ctx, e.runner, "push "+p.Tag, func() *exec.Cmd {
+		// create the push command for the file
+		cmd := p.Command(ctx)
+
+		// capture the end of the push output for the digest
+		//
+		// the output is captured before redaction since
+		// a secret value may be part of the digest
+		out = newTailWriter(pushTailLines)
+		cmd.Stdout = &unredacted{Writer: stdout, raw: out}
+		cmd.Stderr = stderr
+
+		return cmd
+	})
+	if err != nil {
+		return err
+	}
+
+	// capture the digest of the pushed image
+	p.Digest, p.Size = parseDigest(out.String())
+
+	return nil
+}
+
+// Inspect returns the details of the image in the daemon.
+func (e *cliEngine) Inspect(ctx context.Context, ref string) (*ImageInspect, error) {
+	cmd := inspectCmd(ctx, ref)
+
+	// capture the output of the command
+	stdout := new(bytes.Buffer)
+	stderr := new(strings.Builder)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err := runnerOrExec(e.runner).Run(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("unable to inspect image %s: %w: %s", ref, err, strings.TrimSpace(stderr.String()))
+	}
+
+	i := new(ImageInspect)
+
+	err = json.Unmarshal(stdout.Bytes(), i)
+	if err != nil {
+		return nil, fmt.Errorf("unable to unmarshal image %s: %w", ref, err)
+	}
+
+	return i, nil
+}
@@ -0,0 +1,172 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDocker_ImageInspect_Platform(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		image *ImageInspect
+		want  string
+	}{
+		{image: &ImageInspect{Os: "linux", Architecture: "amd64"}, want: "linux/amd64"},
+		{image: &ImageInspect{Os: "linux", Architecture: "arm", Variant: "v7"}, want: "linux/arm/v7"},
+		{image: &ImageInspect{}, want: ""},
+	}
+
+	// run tests
+	for _, test := range tests {
+		if got := test.image.Platform(); got != test.want {
+			t.Errorf("Platform is %s, want %s", got, test.want)
+		}
+	}
+}
+
+func TestDocker_cliEngine_Inspect(t *testing.T) {
+	// setup fake docker binary which outputs the image details
+	fakeDockerScript(t, t.TempDir(), "#!/bin/sh\necho '{\"Id\": \"sha256:1234\", \"Os\": \"linux\", \"Architecture\": \"arm64\"}'\n")
+
+	got, err := new(cliEngine).Inspect(t.Context(), "octocat/hello-world:latest")
+	if err != nil {
+		t.Fatalf("Inspect returned err: %v", err)
+	}
+
+	if got.ID != "sha256:1234" || got.Platform() != "linux/arm64" {
+		t.Errorf("Inspect is %+v, want sha256:1234 for linux/arm64", got)
+	}
+}
+
+func TestDocker_cliEngine_Build_Builder(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name    string
+		failure bool
+		results map[string][]*fakeResult
+	}{
+		{
+			name:    "build",
+			failure: false,
+		},
+		{
+			name:    "build error",
+			failure: true,
+			results: map[string][]*fakeResult{
+				"docker buildx build": {{stderr: "ERROR: failed to solve\n", code: 1}},
+			},
+		},
+		{
+			name:    "bootstrap error",
+			failure: true,
+			results: map[string][]*fakeResult{
+				"docker buildx inspect": {{stderr: "ERROR: failed to bootstrap\n", code: 1}},
+			},
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newFakeRunner(t, test.results)
+
+			b := &Build{
+				Context:   ".",
+				Platforms: []string{"linux/amd64", "linux/arm64"},
+				Tags:      []string{"index.docker.io/octocat/hello-world:latest"},
+			}
+
+			err := (&cliEngine{runner: r}).Build(t.Context(), b)
+
+			if test.failure && err == nil {
+				t.Errorf("Build should have returned err")
+			}
+
+			if !test.failure && err != nil {
+				t.Errorf("Build returned err: %v", err)
+			}
+
+			commands := r.Commands()
+
+			// capture the name of the builder created for the step
+			fields := strings.Fields(commands[0])
+			if len(fields) < 5 || fields[2] != "create" {
+				t.Fatalf("Build should have created a builder first: %v", commands)
+			}
+
+			builder := fields[4]
+
+			// verify the builder is removed once the build is complete
+			want := "docker buildx rm " + builder
+
+			if commands[len(commands)-1] != want {
+				t.Errorf("Build should have removed the builder with %q: %v", want, commands)
+			}
+		})
+	}
+}
+
+func TestDocker_cliEngine_Build_Publish(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name    string
+		failure bool
+		result  *fakeResult
+		builds  int
+	}{
+		{
+			name:    "push error",
+			failure: false,
+			result:  &fakeResult{stderr: "ERROR: failed to solve: failed to push index.docker.io/octocat/hello-world:latest: 503 Service Unavailable\n", code: 1},
+			builds:  2,
+		},
+		{
+			name:    "build error",
+			failure: true,
+			result:  &fakeResult{stderr: "curl: (7) Failed to connect to db port 5432: connection refused\nERROR: failed to solve: process \"/bin/sh -c make test\" did not complete successfully: exit code: 7\n", code: 1},
+			builds:  1,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newFakeRunner(t, map[string][]*fakeResult{
+				"docker buildx build": {test.result, {}},
+			})
+
+			b := &Build{
+				Context:   ".",
+				Platforms: []string{"linux/amd64", "linux/arm64"},
+				Publish:   true,
+				Retry:     &Retry{Attempts: 2, Delay: time.Millisecond},
+				Tags:      []string{"index.docker.io/octocat/hello-world:latest"},
+			}
+
+			err := (&cliEngine{runner: r}).Build(t.Context(), b)
+
+			if test.failure && err == nil {
+				t.Errorf("Build should have returned err")
+			}
+
+			if !test.failure && err != nil {
+				t.Errorf("Build returned err: %v", err)
+			}
+
+			var builds int
+
+			for _, c := range r.Commands() {
+				if strings.HasPrefix(c, "docker buildx build") {
+					builds++
+				}
+			}
+
+			if builds != test.builds {
+				t.Errorf("Build ran %d builds, want %d: %v", builds, test.builds, r.Commands())
+			}
+		})
+	}
+}
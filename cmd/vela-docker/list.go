@@ -0,0 +1,65 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// parseList captures the entries of a list parameter whose entries contain
+// commas (e.g. id=npm,src=/root/.npmrc) from a JSON list of strings or a
+// comma-separated value.
+//
+// Vela provides a list of strings to the plugin as a comma-separated
+// value, so a new entry is started by every field with the provided
+// key and by every field that is not in the format key=value.
+func parseList(raw, key string) ([]string, error) {
+	raw = strings.TrimSpace(raw)
+
+	// check if any entries are provided
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	// check if the entries are provided as a JSON list
+	if strings.HasPrefix(raw, "[") {
+		// variable to store the entries of the list
+		var entries []string
+
+		err := json.Unmarshal([]byte(raw), &entries)
+		if err != nil {
+			return nil, fmt.Errorf("unable to unmarshal list: %w", err)
+		}
+
+		return entries, nil
+	}
+
+	// variables to store the entries of the list
+	var (
+		entries []string
+		current []string
+		hasKey  bool
+	)
+
+	for _, field := range strings.Split(raw, ",") {
+		k, _, ok := strings.Cut(field, "=")
+
+		// check if the field starts a new entry
+		starts := !ok || strings.EqualFold(k, key)
+
+		if starts && (hasKey || (len(current) > 0 && !ok)) {
+			entries = append(entries, strings.Join(current, ","))
+			current, hasKey = nil, false
+		}
+
+		if starts {
+			hasKey = true
+		}
+
+		current = append(current, field)
+	}
+
+	return append(entries, strings.Join(current, ",")), nil
+}
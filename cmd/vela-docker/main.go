@@ -9,6 +9,8 @@ import (
 	"log"
 	"net/mail"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -67,6 +69,9 @@ func main() {
 	// add build flags
 	app.Flags = append(app.Flags, buildFlags...)
 
+	// add credential flags
+	app.Flags = append(app.Flags, credentialFlags...)
+
 	// add daemon flags
 	app.Flags = append(app.Flags, daemonFlags...)
 
@@ -76,9 +81,21 @@ func main() {
 	// add registry flags
 	app.Flags = append(app.Flags, registryFlags...)
 
-	err = app.Run(context.Background(), os.Args)
+	// add result flags
+	app.Flags = append(app.Flags, resultFlags...)
+
+	// add retry flags
+	app.Flags = append(app.Flags, retryFlags...)
+
+	// cancel the running commands when the step is stopped by the executor
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+
+	err = app.Run(ctx, os.Args)
+
+	stop()
+
 	if err != nil {
-		log.Fatal(err)
+		log.Fatal(secretMask.redact(err.Error()))
 	}
 }
 
@@ -104,18 +121,37 @@ func run(ctx context.Context, c *cli.Command) error {
 		logrus.SetLevel(logrus.InfoLevel)
 	}
 
+	// redact the secret values loaded by the plugin from the logs
+	logrus.SetFormatter(&redactFormatter{Formatter: logrus.StandardLogger().Formatter})
+
 	logrus.WithFields(logrus.Fields{
 		"code":     "https://github.com/go-vela/vela-docker",
 		"docs":     "https://go-vela.github.io/docs/plugins/registry/pipeline/docker",
 		"registry": "https://hub.docker.com/r/target/vela-docker",
 	}).Info("Vela Docker Plugin")
 
+	// create the retry settings shared by login and push
+	retry := &Retry{
+		Attempts: c.Int("retry.attempts"),
+		Delay:    c.Duration("retry.delay"),
+		MaxDelay: c.Duration("retry.max-delay"),
+	}
+
+	// create the runner for the commands executed by the plugin
+	runner := new(execRunner)
+
 	// create the plugin
 	p := Plugin{
 		Build: &Build{
 			AddHosts:            c.StringSlice("build.add-hosts"),
+			AutoTag:             c.Bool("build.auto-tag"),
 			BuildArgs:           c.StringSlice("build.build-args"),
-			CacheFrom:           c.String("build.cache-from"),
+			BuildArgsFile:       c.String("build.build-args-file"),
+			BuildArgsFromEnv:    c.StringSlice("build.build-args-from-env"),
+			BuildArgsSecret:     c.StringSlice("build.build-args-secret"),
+			BuildContextsRaw:    c.String("build.build-contexts"),
+			CacheFromRaw:        c.String("build.cache-from"),
+			CacheToRaw:          c.String("build.cache-to"),
 			CGroupParent:        c.String("build.cgroup-parent"),
 			Compress:            c.Bool("build.compress"),
 			Context:             c.String("build.context"),
@@ -126,12 +162,16 @@ func run(ctx context.Context, c *cli.Command) error {
 			ImageIDFile:         c.String("build.image-id-file"),
 			Isolation:           c.String("build.isolation"),
 			Label: &Label{
-				AuthorEmail: c.String("label.author-email"),
-				Commit:      c.String("label.commit"),
-				Created:     time.Now().Format(time.RFC3339),
-				FullName:    c.String("label.full-name"),
-				Number:      c.Int("label.number"),
-				URL:         c.String("label.url"),
+				AuthorEmail:   c.String("label.author-email"),
+				Branch:        c.String("label.branch"),
+				Commit:        c.String("label.commit"),
+				Created:       time.Now().Format(time.RFC3339),
+				DefaultBranch: c.String("label.default-branch"),
+				Event:         c.String("label.event"),
+				FullName:      c.String("label.full-name"),
+				Number:        c.Int("label.number"),
+				Tag:           c.String("label.tag"),
+				URL:           c.String("label.url"),
 			},
 			Labels:        c.StringSlice("build.labels"),
 			Memory:        c.StringSlice("build.memory"),
@@ -140,12 +180,13 @@ func run(ctx context.Context, c *cli.Command) error {
 			NoCache:       c.Bool("build.no-cache"),
 			Output:        c.String("build.output"),
 			Platform:      c.String("build.platform"),
+			Platforms:     c.StringSlice("build.platforms"),
 			Progress:      c.String("build.progress"),
 			Pull:          c.Bool("build.pull"),
 			Quiet:         c.Bool("build.quiet"),
 			Remove:        c.Bool("build.remove"),
 			Repo:          c.String("build.repo"),
-			Secret:        c.String("build.secret"),
+			SecretsRaw:    c.String("build.secrets"),
 			SecurityOpts:  c.StringSlice("build.security-opts"),
 			ShmSizes:      c.StringSlice("build.shm-sizes"),
 			Squash:        c.Bool("build.squash"),
@@ -157,22 +198,58 @@ func run(ctx context.Context, c *cli.Command) error {
 		},
 		Daemon: &Daemon{},
 		Push: &Push{
+			Concurrency:         c.Int("push.concurrency"),
 			DisableContentTrust: c.Bool("push.disable-content-trust"),
+			Retry:               retry,
 		},
 		Registry: &Registry{
-			DryRun:   c.Bool("registry.dry-run"),
-			Name:     c.String("registry.name"),
-			Password: c.String("registry.password"),
-			Username: c.String("registry.username"),
+			Credential: &Credential{
+				AccessKeyID:      c.String("credential.access-key-id"),
+				ClientID:         c.String("credential.client-id"),
+				ClientSecret:     c.String("credential.client-secret"),
+				Endpoint:         c.String("credential.endpoint"),
+				ExchangeEndpoint: c.String("credential.exchange-endpoint"),
+				Region:           c.String("credential.region"),
+				SecretAccessKey:  c.String("credential.secret-access-key"),
+				ServiceAccount:   c.String("credential.service-account"),
+				SessionToken:     c.String("credential.session-token"),
+				TenantID:         c.String("credential.tenant-id"),
+			},
+			CredentialHelper: c.String("registry.credential-helper"),
+			DryRun:           c.Bool("registry.dry-run"),
+			Name:             c.String("registry.name"),
+			Password:         c.String("registry.password"),
+			RegistriesRaw:    c.String("registry.registries"),
+			Retry:            retry,
+			Username:         c.String("registry.username"),
+		},
+		Result: &Result{
+			File:    c.String("result.file"),
+			Outputs: c.String("result.outputs"),
 		},
+		Runner: runner,
 	}
 
 	// validate the plugin
 	err := p.Validate(c.String("daemon"))
 	if err != nil {
-		return err
+		return withParamSource(c, err)
 	}
 
+	// check if the daemon started by the plugin runs without root privileges
+	//
+	// the rootless daemon listens on a socket owned by the user
+	// so every command executed by the plugin is pointed at it
+	if p.Daemon.rootless() {
+		err = os.Setenv("DOCKER_HOST", p.Daemon.Host())
+		if err != nil {
+			return err
+		}
+	}
+
+	// create the engine shared by build, login and push
+	p.Engine = &cliEngine{runner: runner}
+
 	// execute the plugin
 	return p.Exec(ctx)
 }
@@ -4,7 +4,12 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 )
@@ -15,16 +20,60 @@ type Plugin struct {
 	Build *Build
 	// daemon arguments loaded for the plugin
 	Daemon *Daemon
+	// engine for running actions against the daemon
+	Engine Engine
 	// push arguments loaded for the plugin
 	Push *Push
 	// registry arguments loaded for the plugin
 	Registry *Registry
+	// result arguments loaded for the plugin
+	Result *Result
+	// runner for the commands executed by the plugin
+	Runner Runner
 }
 
 // Exec formats and runs the commands for building and publishing a Docker image.
+//
+// The daemon started by the plugin is stopped once the commands complete
+// or the context is canceled.
 func (p *Plugin) Exec(ctx context.Context) error {
 	logrus.Debug("running plugin with provided configuration")
 
+	// create the runner for the commands executed by the plugin
+	runner := runnerOrExec(p.Runner)
+
+	// create the engine for running actions against the daemon
+	engine := p.Engine
+	if engine == nil {
+		engine = &cliEngine{runner: runner}
+	}
+
+	// share the runner and engine with every step of the plugin
+	p.Daemon.Runner = runner
+	p.Build.Engine = engine
+	p.Push.Engine = engine
+	p.Push.Runner = runner
+	p.Registry.Engine = engine
+
+	// stop the docker daemon at the end of the step
+	defer func() {
+		// check if the step was canceled
+		if ctx.Err() != nil {
+			logrus.Warnf("step canceled: %v", context.Cause(ctx))
+		} else {
+			// enforce the budget of the data root after the build
+			pruneErr := p.Daemon.Prune(ctx)
+			if pruneErr != nil {
+				logrus.Warn(pruneErr)
+			}
+		}
+
+		stopErr := p.Daemon.Stop()
+		if stopErr != nil {
+			logrus.Warn(stopErr)
+		}
+	}()
+
 	// start the docker daemon with configuration
 	err := p.Daemon.Exec(ctx)
 	if err != nil {
@@ -32,13 +81,19 @@ func (p *Plugin) Exec(ctx context.Context) error {
 	}
 
 	// output the docker version
-	err = execCmd(versionCmd(ctx))
+	err = engine.Version(ctx)
 	if err != nil {
 		return err
 	}
 
 	// output the docker information
-	err = execCmd(infoCmd(ctx))
+	err = engine.Info(ctx)
+	if err != nil {
+		return err
+	}
+
+	// exchange credentials for short-lived registry tokens
+	err = p.Registry.Exchange(ctx)
 	if err != nil {
 		return err
 	}
@@ -55,59 +110,203 @@ func (p *Plugin) Exec(ctx context.Context) error {
 		return err
 	}
 
+	// publish directly from the builder when the build creates a manifest list
+	//
+	// the images for other builds are loaded into the daemon and pushed from it
+	p.Build.Publish = len(p.Build.Platforms) > 0 && !p.Registry.DryRun
+
+	// retry publishing from the builder with the settings for pushing images
+	p.Build.Retry = p.Push.Retry
+
+	// capture the digest of the image published by the builder
+	if p.Build.Publish {
+		p.Build.MetadataFile = filepath.Join(os.TempDir(), "vela-docker-metadata.json")
+	}
+
 	// execute build configuration
 	err = p.Build.Exec(ctx)
 	if err != nil {
 		return err
 	}
 
+	// capture the platforms of the published images
+	platform := strings.Join(p.Build.Platforms, ",")
+	if len(platform) == 0 {
+		platform = p.Build.Platform
+	}
+
+	if len(platform) == 0 {
+		platform = defaultPlatform()
+	}
+
+	// check if the image was already published by the builder
+	if p.Build.Publish {
+		digest, err := readMetadata(p.Build.MetadataFile)
+		if err != nil {
+			return err
+		}
+
+		// record every tag published with the manifest list
+		for _, t := range p.Build.Tags {
+			p.Result.add(&Image{
+				Tag:      t,
+				Digest:   digest,
+				Platform: platform,
+			})
+		}
+
+		// write the results for the published images
+		return p.Result.Write()
+	}
+
 	// check if registry dry run is enabled
 	if !p.Registry.DryRun {
 		// push all tags
-		for _, t := range p.Build.Tags {
-			// set the tag to be pushed
-			p.Push.Tag = t
+		pushes, err := p.Push.Tags(ctx, p.Build.Tags)
 
-			// execute push configuration
-			err = p.Push.Exec(ctx)
-			if err != nil {
-				return err
+		// record the images published by the pushes
+		for _, push := range pushes {
+			image := &Image{
+				Tag:      push.Tag,
+				Digest:   push.Digest,
+				Size:     push.Size,
+				Platform: platform,
 			}
+
+			// capture the platform of the image from the daemon
+			i, inspectErr := engine.Inspect(ctx, push.Tag)
+			if inspectErr == nil {
+				if len(i.Platform()) > 0 {
+					image.Platform = i.Platform()
+				}
+
+				// fall back to the digest of the repository from the daemon
+				if len(image.Digest) == 0 {
+					image.Digest = repoDigest(push.Tag, i.RepoDigests)
+				}
+			}
+
+			// verify the digest of the published image was captured
+			if len(image.Digest) == 0 {
+				err = errors.Join(err, fmt.Errorf("unable to capture the digest of the published image %s", push.Tag))
+
+				continue
+			}
+
+			p.Result.add(image)
+		}
+
+		if err != nil {
+			return err
 		}
 	}
 
-	return nil
+	// write the results for the published images
+	return p.Result.Write()
 }
 
 // Validate verifies the Plugin is properly configured.
 func (p *Plugin) Validate(daemon string) error {
 	logrus.Debug("validating plugin configuration")
 
+	// redact the Vela secrets mounted for the plugin from the output
+	secretMask.loadSecretFiles(secretsPath)
+
+	// variable to store every problem with the configuration
+	var errs []error
+
 	// serialize daemon settings into plugin
 	if len(daemon) > 0 {
-		err := json.Unmarshal([]byte(daemon), &p.Daemon)
+		// check if the daemon settings are initialized
+		if p.Daemon == nil {
+			p.Daemon = new(Daemon)
+		}
+
+		err := p.Daemon.Unmarshal(daemon)
 		if err != nil {
-			return err
+			errs = append(errs, &paramError{Name: "daemon", Flag: "daemon", Err: err})
 		}
 	}
 
+	// validate daemon configuration
+	err := p.Daemon.Validate()
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	// when user adds additional registries
+	err = p.Registry.Unmarshal()
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	// redact the registry credentials from the output
+	secretMask.add(p.Registry.secrets()...)
+
 	// validate registry configuration
-	err := p.Registry.Validate()
+	err = p.Registry.Validate()
 	if err != nil {
-		return err
+		errs = append(errs, err)
+	}
+
+	// validate push configuration
+	err = p.Push.Validate()
+	if err != nil {
+		errs = append(errs, err)
 	}
 
 	// when user adds configuration additional options
 	err = p.Build.Unmarshal()
 	if err != nil {
-		return err
+		errs = append(errs, err)
 	}
 
+	// redact the secret build arguments from the output
+	secretMask.add(p.Build.secrets()...)
+
 	// validate build configuration
 	err = p.Build.Validate()
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	// check if any problems were found with the configuration
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	// normalize the tags against the registry and repository
+	//
+	// the resolved tags are used for both building and pushing the image
+	p.Build.Tags, err = resolveTags(p.Build.Tags, p.Registry.Name, p.Build.Repo)
 	if err != nil {
 		return err
 	}
 
+	// normalize the registry caches against the registry
+	//
+	// the caches are authenticated with the credentials for the registries
+	p.Build.CacheFrom, err = resolveCaches(p.Build.CacheFrom, p.Registry.Name)
+	if err != nil {
+		return fmt.Errorf("invalid cache_from: %w", err)
+	}
+
+	p.Build.CacheTo, err = resolveCaches(p.Build.CacheTo, p.Registry.Name)
+	if err != nil {
+		return fmt.Errorf("invalid cache_to: %w", err)
+	}
+
+	// alert user when a registry cache has no credentials provided
+	for _, host := range cacheHosts(slices.Concat(p.Build.CacheFrom, p.Build.CacheTo)) {
+		if !p.Registry.authenticates(host) {
+			logrus.Warnf("no credentials provided for cache registry %s - add it to registries to authenticate", host)
+		}
+	}
+
+	// preview the computed tags when the image will not be published
+	if p.Registry.DryRun {
+		logrus.Infof("dry_run enabled - computed tags: %s", strings.Join(p.Build.Tags, ", "))
+	}
+
 	return nil
 }
@@ -3,11 +3,349 @@
 package main
 
 import (
+	"context"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/spf13/afero"
 )
 
-func TestDocker_Plugin_Exec(_ *testing.T) {
-	// TODO Write test
+// execPlugin creates a plugin publishing the image to
+// the provided tags with the commands run by the runner.
+func execPlugin(r Runner, tags ...string) *Plugin {
+	retry := &Retry{Attempts: 2, Delay: time.Millisecond}
+
+	return &Plugin{
+		Build: &Build{
+			Context: ".",
+			CPU:     &CPU{},
+			Label:   &Label{},
+			Tags:    tags,
+		},
+		Daemon: &Daemon{},
+		Push: &Push{
+			Retry: retry,
+		},
+		Registry: &Registry{
+			Name:     "index.docker.io",
+			Username: "octocat",
+			Password: "superSecretPassword",
+			Retry:    retry,
+		},
+		Result: &Result{
+			File: "/vela/results.json",
+		},
+		Runner: r,
+	}
+}
+
+// commandNames returns the binary and first argument of the commands.
+func commandNames(commands []string) []string {
+	var names []string
+
+	for _, c := range commands {
+		fields := strings.Fields(c)
+
+		names = append(names, strings.Join(fields[:min(len(fields), 2)], " "))
+	}
+
+	return names
+}
+
+func TestDocker_Plugin_Exec(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	r := newFakeRunner(t, map[string][]*fakeResult{
+		"docker push index.docker.io/octocat/hello-world:latest": {{stdout: "latest: digest: sha256:a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2 size: 528\n"}},
+		"docker push index.docker.io/octocat/hello-world:1.0.0": {
+			{stderr: "received unexpected HTTP status: 503 Service Unavailable\n", code: 1},
+			{stdout: "1.0.0: digest: sha256:a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2 size: 528\n"},
+		},
+		"docker image inspect": {{stdout: `{"Id": "sha256:1234", "Os": "linux", "Architecture": "arm64"}`}},
+	})
+
+	p := execPlugin(r, "index.docker.io/octocat/hello-world:latest", "index.docker.io/octocat/hello-world:1.0.0")
+
+	err := p.Exec(t.Context())
+	if err != nil {
+		t.Fatalf("Exec returned err: %v", err)
+	}
+
+	want := []string{
+		"dockerd --validate",
+		"dockerd --config-file",
+		"docker version",
+		"docker version",
+		"docker info",
+		"docker login",
+		"docker build",
+		"docker push",
+		"docker push",
+		"docker push",
+		"docker image",
+		"docker image",
+	}
+
+	if got := commandNames(r.Commands()); !reflect.DeepEqual(got, want) {
+		t.Errorf("Exec commands are %v, want %v", got, want)
+	}
+
+	// verify the daemon is stopped when the plugin completes
+	if got := r.Signals(); !reflect.DeepEqual(got, []string{"terminated"}) {
+		t.Errorf("Exec signals are %v, want [terminated]", got)
+	}
+
+	// verify the password is never provided as an argument
+	if strings.Contains(strings.Join(r.Commands(), " "), "superSecretPassword") {
+		t.Errorf("Exec commands should not contain the password: %v", r.Commands())
+	}
+
+	got, _ := afero.ReadFile(appFS, "/vela/results.json")
+
+	for _, s := range []string{`"tag": "index.docker.io/octocat/hello-world:1.0.0"`, `"digest": "sha256:a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2"`, `"platform": "linux/arm64"`} {
+		if !strings.Contains(string(got), s) {
+			t.Errorf("Exec results should contain %s: %s", s, got)
+		}
+	}
+}
+
+func TestDocker_Plugin_Exec_Digest(t *testing.T) {
+	// setup types
+	digest := "sha256:a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2"
+
+	// setup tests
+	tests := []struct {
+		name    string
+		failure bool
+		results map[string][]*fakeResult
+	}{
+		{
+			name:    "push output",
+			failure: false,
+			results: map[string][]*fakeResult{
+				"docker push":          {{stdout: "latest: digest: " + digest + " size: 528\n"}},
+				"docker image inspect": {{stdout: `{"Id": "sha256:1234", "Os": "linux", "Architecture": "arm64"}`}},
+			},
+		},
+		{
+			name:    "repo digests",
+			failure: false,
+			results: map[string][]*fakeResult{
+				"docker push":          {{stdout: "latest: Pushed\n"}},
+				"docker image inspect": {{stdout: `{"Id": "sha256:1234", "RepoDigests": ["ghcr.io/octocat/hello-world@sha256:ffff", "octocat/hello-world@` + digest + `"]}`}},
+			},
+		},
+		{
+			name:    "no digest",
+			failure: true,
+			results: map[string][]*fakeResult{
+				"docker push":          {{stdout: "latest: Pushed\n"}},
+				"docker image inspect": {{stdout: `{"Id": "sha256:1234"}`}},
+			},
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// setup filesystem
+			appFS = afero.NewMemMapFs()
+
+			// setup redaction with a secret value which is part of the digest
+			mask := secretMask
+
+			t.Cleanup(func() {
+				secretMask = mask
+			})
+
+			secretMask = new(redactor)
+			secretMask.add("a1b2a1b2a1b2")
+
+			r := newFakeRunner(t, test.results)
+
+			p := execPlugin(r, "index.docker.io/octocat/hello-world:latest")
+
+			err := p.Exec(t.Context())
+
+			if test.failure {
+				if err == nil || !strings.Contains(err.Error(), "unable to capture the digest") {
+					t.Errorf("Exec should have returned err for the missing digest: %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Exec returned err: %v", err)
+			}
+
+			got, _ := afero.ReadFile(appFS, "/vela/results.json")
+
+			if !strings.Contains(string(got), `"digest": "`+digest+`"`) {
+				t.Errorf("Exec results should contain the digest %s: %s", digest, got)
+			}
+		})
+	}
+}
+
+func TestDocker_Plugin_Exec_CacheTo(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	r := newFakeRunner(t, map[string][]*fakeResult{
+		"docker push":          {{stdout: "latest: digest: sha256:a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2a1b2 size: 528\n"}},
+		"docker image inspect": {{stdout: `{"Id": "sha256:1234", "Os": "linux", "Architecture": "arm64"}`}},
+	})
+
+	p := execPlugin(r, "index.docker.io/octocat/hello-world:latest")
+	p.Build.CacheTo = []string{"type=registry,ref=index.docker.io/octocat/hello-world:buildcache"}
+
+	err := p.Exec(t.Context())
+	if err != nil {
+		t.Fatalf("Exec returned err: %v", err)
+	}
+
+	// verify the image is loaded into the daemon and pushed from it
+	var build string
+
+	for _, c := range r.Commands() {
+		if strings.HasPrefix(c, "docker buildx build") {
+			build = c
+		}
+	}
+
+	if !strings.Contains(build, "--load") || strings.Contains(build, "--push") {
+		t.Errorf("Exec build should load the image instead of pushing it: %s", build)
+	}
+
+	if got := commandNames(r.Commands()); !slices.Contains(got, "docker push") {
+		t.Errorf("Exec commands are %v, want docker push", got)
+	}
+}
+
+func TestDocker_Plugin_Exec_DryRun(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	r := newFakeRunner(t, nil)
+
+	p := execPlugin(r, "index.docker.io/octocat/hello-world:latest")
+	p.Registry.DryRun = true
+
+	err := p.Exec(t.Context())
+	if err != nil {
+		t.Fatalf("Exec returned err: %v", err)
+	}
+
+	want := []string{"dockerd --validate", "dockerd --config-file", "docker version", "docker version", "docker info", "docker build"}
+
+	if got := commandNames(r.Commands()); !reflect.DeepEqual(got, want) {
+		t.Errorf("Exec commands are %v, want %v", got, want)
+	}
+
+	got, _ := afero.ReadFile(appFS, "/vela/results.json")
+	if string(got) != "[]" {
+		t.Errorf("Exec results are %s, want []", got)
+	}
+}
+
+func TestDocker_Plugin_Exec_Failure(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name    string
+		results map[string][]*fakeResult
+		want    []string
+		err     string
+	}{
+		{
+			name: "daemon exits",
+			results: map[string][]*fakeResult{
+				"dockerd --config-file": {{stderr: "failed to start daemon: permission denied\n", code: 1}},
+				"docker version":        {{code: 1}},
+			},
+			want: []string{"dockerd --validate", "dockerd --config-file", "docker version"},
+			err:  "failed to start daemon: permission denied",
+		},
+		{
+			name: "login denied",
+			results: map[string][]*fakeResult{
+				"docker login": {{stderr: "unauthorized: incorrect username or password\n", code: 1}},
+			},
+			want: []string{"dockerd --validate", "dockerd --config-file", "docker version", "docker version", "docker info", "docker login"},
+			err:  "exit status 1",
+		},
+		{
+			name: "build fails",
+			results: map[string][]*fakeResult{
+				"docker build": {{stderr: "failed to solve: dockerfile parse error\n", code: 1}},
+			},
+			want: []string{"dockerd --validate", "dockerd --config-file", "docker version", "docker version", "docker info", "docker login", "docker build"},
+			err:  "exit status 1",
+		},
+		{
+			name: "push denied",
+			results: map[string][]*fakeResult{
+				"docker push": {{stderr: "denied: requested access to the resource is denied\n", code: 1}},
+			},
+			want: []string{"dockerd --validate", "dockerd --config-file", "docker version", "docker version", "docker info", "docker login", "docker build", "docker push"},
+			err:  "index.docker.io/octocat/hello-world:latest",
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// setup filesystem
+			appFS = afero.NewMemMapFs()
+
+			r := newFakeRunner(t, test.results)
+
+			p := execPlugin(r, "index.docker.io/octocat/hello-world:latest")
+			p.Daemon.Readiness = &Readiness{Timeout: Duration(time.Second), Backoff: Duration(time.Millisecond)}
+
+			err := p.Exec(t.Context())
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Exec should have returned err containing %q: %v", test.err, err)
+			}
+
+			if got := commandNames(r.Commands()); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Exec commands are %v, want %v", got, test.want)
+			}
+
+			// verify no results are written for a failed publish
+			if ok, _ := afero.Exists(appFS, "/vela/results.json"); ok {
+				t.Errorf("Exec should not have written results")
+			}
+		})
+	}
+}
+
+func TestDocker_Plugin_Exec_Canceled(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	r := newFakeRunner(t, nil)
+
+	p := execPlugin(r, "index.docker.io/octocat/hello-world:latest")
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	// the fake runner completes the commands regardless of the context
+	_ = p.Exec(ctx)
+
+	// verify the daemon is stopped when the step is canceled
+	if got := r.Signals(); !reflect.DeepEqual(got, []string{"terminated"}) {
+		t.Errorf("Exec signals are %v, want [terminated]", got)
+	}
 }
 
 func TestDocker_Plugin_Validate(t *testing.T) {
@@ -47,3 +385,119 @@ func TestDocker_Plugin_Validate_BadBuild(t *testing.T) {
 		t.Errorf("Validate should have returned err")
 	}
 }
+
+func TestDocker_Plugin_Validate_ResolvesTags(t *testing.T) {
+	// setup types
+	p := &Plugin{
+		Build: &Build{
+			Context: ".",
+			Repo:    "octocat/hello-world",
+			Tags:    []string{"latest", "octocat/hello-world:1"},
+		},
+		Push: &Push{},
+		Registry: &Registry{
+			Name:   "ghcr.io",
+			DryRun: true,
+		},
+	}
+
+	want := []string{"ghcr.io/octocat/hello-world:latest", "ghcr.io/octocat/hello-world:1"}
+
+	err := p.Validate("")
+	if err != nil {
+		t.Errorf("Validate returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(p.Build.Tags, want) {
+		t.Errorf("Validate tags are %v, want %v", p.Build.Tags, want)
+	}
+}
+
+func TestDocker_Plugin_Validate_ResolvesCaches(t *testing.T) {
+	// setup types
+	p := &Plugin{
+		Build: &Build{
+			CacheFromRaw: "octocat/hello-world:cache,type=local,src=/tmp/cache",
+			CacheToRaw:   "type=registry,ref=octocat/hello-world:cache,mode=max",
+			Context:      ".",
+			Repo:         "octocat/hello-world",
+			Tags:         []string{"latest"},
+		},
+		Push: &Push{},
+		Registry: &Registry{
+			Name:   "ghcr.io",
+			DryRun: true,
+		},
+	}
+
+	wantFrom := []string{"ghcr.io/octocat/hello-world:cache", "type=local,src=/tmp/cache"}
+	wantTo := []string{"type=registry,ref=ghcr.io/octocat/hello-world:cache,mode=max"}
+
+	err := p.Validate("")
+	if err != nil {
+		t.Errorf("Validate returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(p.Build.CacheFrom, wantFrom) {
+		t.Errorf("Validate cache_from is %v, want %v", p.Build.CacheFrom, wantFrom)
+	}
+
+	if !reflect.DeepEqual(p.Build.CacheTo, wantTo) {
+		t.Errorf("Validate cache_to is %v, want %v", p.Build.CacheTo, wantTo)
+	}
+}
+
+func TestDocker_Plugin_Validate_UnknownDaemonKey(t *testing.T) {
+	// setup types
+	p := &Plugin{
+		Build: &Build{
+			Context: ".",
+			Tags:    []string{"latest"},
+		},
+		Daemon:   &Daemon{},
+		Push:     &Push{},
+		Registry: &Registry{Name: "index.docker.io", DryRun: true},
+	}
+
+	err := p.Validate(`{"registry_mirror": ["mirror.gcr.io"]}`)
+
+	want := `invalid daemon parameter: unknown key "registry_mirror" (did you mean "registry_mirrors"?)`
+
+	if err == nil || err.Error() != want {
+		t.Errorf("Validate is %v, want %s", err, want)
+	}
+}
+
+func TestDocker_Plugin_Validate_Errors(t *testing.T) {
+	// setup types
+	p := &Plugin{
+		Build: &Build{
+			Context:    ".",
+			SecretsRaw: `[{"id": "npm"`,
+		},
+		Daemon: &Daemon{},
+		Push:   &Push{},
+		Registry: &Registry{
+			Name:          "index.docker.io",
+			DryRun:        true,
+			RegistriesRaw: `[{"name": "ghcr.io"`,
+		},
+	}
+
+	err := p.Validate(`{"registry_mirror": ["mirror.gcr.io"]}`)
+	if err == nil {
+		t.Fatalf("Validate should have returned err")
+	}
+
+	// verify every problem with the configuration is returned at once
+	for _, want := range []string{
+		`unknown key "registry_mirror"`,
+		"unable to unmarshal registries",
+		"unable to unmarshal secrets",
+		"no build tags provided",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate err should contain %q: %v", want, err)
+		}
+	}
+}
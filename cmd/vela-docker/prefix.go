@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// prefixWriter is an io.Writer that prepends a prefix to every
+// line so the output of concurrent commands stays readable.
+type prefixWriter struct {
+	// mutex shared by every writer of the same output
+	mu *sync.Mutex
+	// output the prefixed lines are written to
+	out io.Writer
+	// prefix prepended to every line
+	prefix []byte
+	// incomplete line waiting for a newline
+	partial []byte
+}
+
+// newPrefixWriter creates a prefixWriter for the output which
+// synchronizes complete lines with the provided mutex.
+func newPrefixWriter(out io.Writer, mu *sync.Mutex, prefix string) *prefixWriter {
+	return &prefixWriter{
+		mu:     mu,
+		out:    out,
+		prefix: []byte(prefix),
+	}
+}
+
+// Write captures the provided bytes and writes
+// every complete line with the prefix.
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	// append the bytes to the incomplete line
+	w.partial = append(w.partial, p...)
+
+	// split off every complete line
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+
+		err := w.write(w.partial[:i+1])
+		if err != nil {
+			return 0, err
+		}
+
+		w.partial = w.partial[i+1:]
+	}
+
+	return len(p), nil
+}
+
+// Flush writes any incomplete line with the prefix.
+func (w *prefixWriter) Flush() error {
+	// check if an incomplete line is waiting
+	if len(w.partial) == 0 {
+		return nil
+	}
+
+	line := append(w.partial, '\n')
+	w.partial = nil
+
+	return w.write(line)
+}
+
+// write outputs the line with the prefix while holding the mutex.
+func (w *prefixWriter) write(line []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	_, err := w.out.Write(append(w.prefix[:len(w.prefix):len(w.prefix)], line...))
+
+	return err
+}
@@ -0,0 +1,38 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestDocker_prefixWriter(t *testing.T) {
+	// setup types
+	out := new(bytes.Buffer)
+	mu := new(sync.Mutex)
+
+	foo := newPrefixWriter(out, mu, "[foo] ")
+	bar := newPrefixWriter(out, mu, "[bar] ")
+
+	_, _ = foo.Write([]byte("first "))
+	_, _ = bar.Write([]byte("one\ntwo\n"))
+	_, _ = foo.Write([]byte("line\nsecond"))
+
+	err := foo.Flush()
+	if err != nil {
+		t.Errorf("Flush returned err: %v", err)
+	}
+
+	err = bar.Flush()
+	if err != nil {
+		t.Errorf("Flush returned err: %v", err)
+	}
+
+	want := "[bar] one\n[bar] two\n[foo] first line\n[foo] second\n"
+
+	if out.String() != want {
+		t.Errorf("prefixWriter output is %q, want %q", out.String(), want)
+	}
+}
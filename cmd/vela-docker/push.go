@@ -4,24 +4,57 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"os/exec"
+	"strings"
+	"sync"
 
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v3"
 )
 
-const pushAction = "push"
+const (
+	pushAction = "push"
+
+	// pushTailLines is the number of lines of push output
+	// captured to read the digest of the pushed image.
+	pushTailLines = 5
+)
 
 // Push represents the plugin configuration for push information.
 type Push struct {
+	// enables setting the maximum number of tags pushed at the same time
+	Concurrency int
 	// enables naming and optionally a tag in the 'name:tag' format
 	Tag string
 	// enables skipping image verification (default true)
 	DisableContentTrust bool
+	// digest of the image captured from the push
+	Digest string
+	// used for running the push against the daemon
+	Engine Engine
+	// enables retrying the push when it fails with a transient error
+	Retry *Retry
+	// used for writing the output of the push
+	Runner Runner
+	// size of the image manifest captured from the push
+	Size int64
 }
 
 // pushFlags represents for push settings on the cli.
 var pushFlags = []cli.Flag{
+	&cli.IntFlag{
+		Name:  "push.concurrency",
+		Usage: "enables setting the maximum number of tags pushed at the same time",
+		Value: 1,
+		Sources: cli.NewValueSourceChain(
+			cli.EnvVar("PARAMETER_PUSH_CONCURRENCY"),
+			cli.EnvVar("DOCKER_PUSH_CONCURRENCY"),
+			cli.File("/vela/parameters/docker/push_concurrency"),
+			cli.File("/vela/secrets/docker/push_concurrency"),
+		),
+	},
 	&cli.BoolFlag{
 		Name:  "push.disable-content-trust",
 		Usage: "enables skipping image verification (default true)",
@@ -57,16 +90,119 @@ func (p *Push) Command(ctx context.Context) *exec.Cmd {
 
 // Exec formats and runs the commands for pushing a Docker image.
 func (p *Push) Exec(ctx context.Context) error {
+	runner := runnerOrExec(p.Runner)
+
+	return p.exec(ctx, runner.Stdout(), runner.Stderr())
+}
+
+// Tags runs the commands for pushing every tag of a Docker image with up
+// to Concurrency pushes running at the same time and returns the pushes
+// that succeeded.
+//
+// Every tag is pushed even when one fails and the error lists every tag that failed.
+func (p *Push) Tags(ctx context.Context, tags []string) ([]*Push, error) {
+	logrus.Tracef("pushing %d tags with a concurrency of %d", len(tags), p.concurrency())
+
+	// variables to store the result of every push
+	pushes := make([]*Push, len(tags))
+	errs := make([]error, len(tags))
+
+	// mutex to synchronize the output of the pushes
+	mu := new(sync.Mutex)
+
+	// writers for the output of the pushes
+	runner := runnerOrExec(p.Runner)
+
+	// semaphore to bound the number of concurrent pushes
+	sem := make(chan struct{}, p.concurrency())
+
+	var wg sync.WaitGroup
+
+	for i, tag := range tags {
+		// create a copy of the push configuration for the tag
+		push := *p
+		push.Tag = tag
+
+		pushes[i] = &push
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			// check if the push output needs to be prefixed
+			if p.concurrency() == 1 {
+				errs[i] = push.exec(ctx, runner.Stdout(), runner.Stderr())
+
+				return
+			}
+
+			stdout := newPrefixWriter(runner.Stdout(), mu, "["+tag+"] ")
+			stderr := newPrefixWriter(runner.Stderr(), mu, "["+tag+"] ")
+
+			errs[i] = push.exec(ctx, stdout, stderr)
+
+			_ = stdout.Flush()
+			_ = stderr.Flush()
+		}()
+	}
+
+	wg.Wait()
+
+	// variables to store the successful and failed pushes
+	var (
+		pushed []*Push
+		failed []string
+	)
+
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", tags[i], err))
+
+			continue
+		}
+
+		pushed = append(pushed, pushes[i])
+	}
+
+	// check if any of the pushes failed
+	if len(failed) > 0 {
+		return pushed, fmt.Errorf("unable to push %d of %d tags:\n  %s", len(failed), len(tags), strings.Join(failed, "\n  "))
+	}
+
+	return pushed, nil
+}
+
+// exec runs the commands for pushing a Docker image
+// with the output written to the provided writers.
+func (p *Push) exec(ctx context.Context, stdout, stderr io.Writer) error {
 	logrus.Trace("running push with provided configuration")
 
-	// create the push command for the file
-	cmd := p.Command(ctx)
+	// run the push with the engine
+	return engineOrCLI(p.Engine).Push(ctx, p, stdout, stderr)
+}
+
+// Validate verifies the Push is properly configured.
+func (p *Push) Validate() error {
+	logrus.Trace("validating push plugin configuration")
+
+	// verify the concurrency is not negative
+	if p.Concurrency < 0 {
+		return fmt.Errorf("invalid push_concurrency %d: must not be negative", p.Concurrency)
+	}
+
+	return p.Retry.Validate()
+}
 
-	// run the push command for the file
-	err := execCmd(cmd)
-	if err != nil {
-		return err
+// concurrency returns the maximum number of concurrent pushes.
+func (p *Push) concurrency() int {
+	// default to pushing one tag at a time
+	if p.Concurrency < 1 {
+		return 1
 	}
 
-	return nil
+	return p.Concurrency
 }
@@ -6,6 +6,7 @@ import (
 	"os/exec"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestDocker_Push_Command(t *testing.T) {
@@ -36,3 +37,131 @@ func TestDocker_Push_Exec_Error(t *testing.T) {
 		t.Errorf("Exec should have returned err")
 	}
 }
+
+func TestDocker_Push_Exec_Retry(t *testing.T) {
+	// setup types
+	digest := "sha256:" + strings.Repeat("a", 64)
+
+	count := fakeDocker(t, 1, "received unexpected HTTP status: 502 Bad Gateway", "latest: digest: "+digest+" size: 528")
+
+	p := &Push{
+		Tag: "octocat/hello-world:latest",
+		Retry: &Retry{
+			Attempts: 2,
+			Delay:    time.Millisecond,
+		},
+	}
+
+	err := p.Exec(t.Context())
+	if err != nil {
+		t.Errorf("Exec returned err: %v", err)
+	}
+
+	if got := attempts(t, count); got != "2" {
+		t.Errorf("Exec ran %s attempts, want 2", got)
+	}
+
+	if p.Digest != digest || p.Size != 528 {
+		t.Errorf("Exec digest is %s %d, want %s 528", p.Digest, p.Size, digest)
+	}
+}
+
+func TestDocker_Push_Tags(t *testing.T) {
+	// setup types
+	digest := "sha256:" + strings.Repeat("a", 64)
+
+	// the fake CLI fails to push any tag containing "broken"
+	fakeDockerScript(t, t.TempDir(), `#!/bin/sh
+case "$2" in
+  *broken*)
+    echo "denied: requested access to the resource is denied" >&2
+    exit 1
+    ;;
+esac
+echo "latest: digest: `+digest+` size: 528"
+`)
+
+	p := &Push{
+		Concurrency: 2,
+	}
+
+	tags := []string{
+		"octocat/hello-world:1",
+		"octocat/hello-world:broken-1",
+		"octocat/hello-world:1.2",
+		"octocat/hello-world:broken-2",
+		"octocat/hello-world:1.2.3",
+	}
+
+	pushes, err := p.Tags(t.Context(), tags)
+	if err == nil {
+		t.Errorf("Tags should have returned err")
+	}
+
+	for _, tag := range []string{"octocat/hello-world:broken-1", "octocat/hello-world:broken-2"} {
+		if err != nil && !strings.Contains(err.Error(), tag) {
+			t.Errorf("Tags err should list %s: %v", tag, err)
+		}
+	}
+
+	if len(pushes) != 3 {
+		t.Errorf("Tags pushed %d tags, want 3", len(pushes))
+	}
+
+	for i, want := range []string{"octocat/hello-world:1", "octocat/hello-world:1.2", "octocat/hello-world:1.2.3"} {
+		if i >= len(pushes) {
+			break
+		}
+
+		if pushes[i].Tag != want || pushes[i].Digest != digest {
+			t.Errorf("Tags push is %s@%s, want %s@%s", pushes[i].Tag, pushes[i].Digest, want, digest)
+		}
+	}
+
+	// verify the shared push configuration was not modified
+	if len(p.Tag) > 0 || len(p.Digest) > 0 {
+		t.Errorf("Tags should not modify the push configuration: %+v", p)
+	}
+}
+
+func TestDocker_Push_Validate(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		failure bool
+		push    *Push
+	}{
+		{
+			failure: false,
+			push:    &Push{Concurrency: 4},
+		},
+		{
+			failure: false,
+			push:    &Push{},
+		},
+		{
+			failure: true,
+			push:    &Push{Concurrency: -1},
+		},
+		{
+			failure: true,
+			push:    &Push{Retry: &Retry{Attempts: 0}},
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		err := test.push.Validate()
+
+		if test.failure {
+			if err == nil {
+				t.Errorf("Validate should have returned err for %+v", test.push)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Validate returned err: %v", err)
+		}
+	}
+}
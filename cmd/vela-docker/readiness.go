@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultReadinessTimeout is the maximum time to wait for the daemon by default.
+	defaultReadinessTimeout = 30 * time.Second
+	// defaultReadinessBackoff is the initial delay between readiness checks by default.
+	defaultReadinessBackoff = 250 * time.Millisecond
+	// defaultReadinessMaxBackoff is the maximum delay between readiness checks by default.
+	defaultReadinessMaxBackoff = 2 * time.Second
+)
+
+type (
+	// Readiness represents the plugin configuration for waiting on the daemon.
+	Readiness struct {
+		// enables setting the maximum time to wait for the daemon to accept connections (default 30s)
+		Timeout Duration
+		// enables setting the initial delay between readiness checks (default 250ms)
+		Backoff Duration
+		// enables setting the maximum delay between readiness checks (default 2s)
+		MaxBackoff Duration `json:"max_backoff"`
+	}
+
+	// Duration represents a time.Duration that is configured
+	// with a duration string (e.g. "30s") or a number of seconds.
+	Duration time.Duration
+)
+
+// UnmarshalJSON captures the duration from a string or a number of seconds.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	// variable to store the raw duration
+	var raw any
+
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	switch v := raw.(type) {
+	case float64:
+		*d = Duration(v * float64(time.Second))
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration %s", string(data))
+	}
+
+	return nil
+}
+
+// Wait polls the provided check until it succeeds, the daemon
+// exits or the configured timeout is reached. The delay between
+// checks starts at the backoff and doubles up to the max backoff.
+func (r *Readiness) Wait(ctx context.Context, exited <-chan error, check func(context.Context) error) error {
+	logrus.Trace("waiting for docker daemon to be ready")
+
+	// set the readiness settings with defaults
+	timeout, backoff, maxBackoff := defaultReadinessTimeout, defaultReadinessBackoff, defaultReadinessMaxBackoff
+
+	// check if any readiness settings were provided
+	if r != nil {
+		if r.Timeout > 0 {
+			timeout = time.Duration(r.Timeout)
+		}
+
+		if r.Backoff > 0 {
+			backoff = time.Duration(r.Backoff)
+		}
+
+		if r.MaxBackoff > 0 {
+			maxBackoff = time.Duration(r.MaxBackoff)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for attempt := 1; ; attempt++ {
+		err := check(ctx)
+		if err == nil {
+			logrus.Debugf("docker daemon ready after %d attempt(s)", attempt)
+
+			return nil
+		}
+
+		logrus.Debugf("docker daemon not ready after %d attempt(s): %v", attempt, err)
+
+		select {
+		case exitErr := <-exited:
+			// check if the daemon exited successfully
+			if exitErr == nil {
+				exitErr = errors.New("exit status 0")
+			}
+
+			return fmt.Errorf("docker daemon exited before becoming ready: %w", exitErr)
+		case <-ctx.Done():
+			return fmt.Errorf("docker daemon not ready after %s (%d attempts): %w", timeout, attempt, err)
+		case <-time.After(backoff):
+		}
+
+		// increase the delay for the next check
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
@@ -0,0 +1,129 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDocker_Duration_UnmarshalJSON(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		failure bool
+		data    string
+		want    Duration
+	}{
+		{
+			failure: false,
+			data:    `"1m30s"`,
+			want:    Duration(90 * time.Second),
+		},
+		{
+			failure: false,
+			data:    `45`,
+			want:    Duration(45 * time.Second),
+		},
+		{
+			failure: true,
+			data:    `"soon"`,
+		},
+		{
+			failure: true,
+			data:    `true`,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		var got Duration
+
+		err := json.Unmarshal([]byte(test.data), &got)
+
+		if test.failure {
+			if err == nil {
+				t.Errorf("UnmarshalJSON should have returned err for %s", test.data)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("UnmarshalJSON returned err: %v", err)
+		}
+
+		if got != test.want {
+			t.Errorf("UnmarshalJSON is %v, want %v", got, test.want)
+		}
+	}
+}
+
+func TestDocker_Readiness_Wait(t *testing.T) {
+	// setup types
+	r := &Readiness{
+		Timeout: Duration(time.Second),
+		Backoff: Duration(time.Millisecond),
+	}
+
+	attempts := 0
+
+	err := r.Wait(t.Context(), make(chan error), func(context.Context) error {
+		attempts++
+
+		if attempts < 3 {
+			return errors.New("not ready")
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Wait returned err: %v", err)
+	}
+
+	if attempts != 3 {
+		t.Errorf("Wait made %d attempts, want 3", attempts)
+	}
+}
+
+func TestDocker_Readiness_Wait_Timeout(t *testing.T) {
+	// setup types
+	r := &Readiness{
+		Timeout:    Duration(50 * time.Millisecond),
+		Backoff:    Duration(time.Millisecond),
+		MaxBackoff: Duration(5 * time.Millisecond),
+	}
+
+	err := r.Wait(t.Context(), make(chan error), func(context.Context) error {
+		return errors.New("connection refused")
+	})
+	if err == nil {
+		t.Errorf("Wait should have returned err")
+	}
+
+	if !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("Wait returned err %v, want last check error", err)
+	}
+}
+
+func TestDocker_Readiness_Wait_Exited(t *testing.T) {
+	// setup types
+	var r *Readiness
+
+	exited := make(chan error, 1)
+	exited <- errors.New("exit status 1")
+
+	err := r.Wait(t.Context(), exited, func(context.Context) error {
+		return errors.New("connection refused")
+	})
+	if err == nil {
+		t.Errorf("Wait should have returned err")
+	}
+
+	if !strings.Contains(err.Error(), "exit status 1") {
+		t.Errorf("Wait returned err %v, want daemon exit error", err)
+	}
+}
@@ -0,0 +1,244 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+
+	"github.com/go-vela/server/constants"
+)
+
+// redactor replaces every secret value loaded by the plugin
+// with constants.SecretMask before it is output.
+type redactor struct {
+	mu sync.RWMutex
+	// secret values ordered from longest to shortest so a value
+	// containing another value is replaced as a whole
+	values []string
+}
+
+// secretMask is the redactor for every secret value loaded by the plugin.
+var secretMask = new(redactor)
+
+// urlCredentialsRegex represents the password of the credentials embedded in a URL.
+var urlCredentialsRegex = regexp.MustCompile(`(://[^:/@\s]+:)[^@/\s]+@`)
+
+// jsonFieldRegex represents a line of a JSON object with the name and value of a field.
+var jsonFieldRegex = regexp.MustCompile(`^"[^"]*"\s*:\s*(.*)$`)
+
+// redactLineLength represents the minimum length of a line
+// of a multi-line secret value which is redacted on its own.
+const redactLineLength = 8
+
+// structuralTokens represents the lines of a multi-line secret
+// value (e.g. JSON credentials) which are not secret on their own.
+var structuralTokens = []string{"{", "}", "[", "]", "{}", "[]", "true", "false", "null"}
+
+// redactMinLength represents the minimum length of a
+// secret value which is redacted from output.
+const redactMinLength = 4
+
+// parameterFiles represents the parameters of the plugin which may be
+// mounted as Vela secrets but only configure the plugin, so their values
+// are not redacted (e.g. a repo or a flag like dry_run).
+var parameterFiles = []string{
+	"add_hosts", "auto_tag", "aws_region", "azure_client_id", "azure_tenant_id",
+	"build_args", "build_args_file", "build_args_from_env", "build_args_secret",
+	"build_contexts", "cache_from", "cache_to", "cgroup_parent", "compress",
+	"context", "cpu", "credential_endpoint", "credential_exchange_endpoint",
+	"credential_helper", "daemon", "disable-content-trust", "dry_run",
+	"file", "force_rm", "image_id_file", "isolation", "labels", "log_level",
+	"memory", "memory_swaps", "network", "no_cache", "output", "platform",
+	"platforms", "progress", "pull", "push_concurrency", "quiet", "registries",
+	"registry", "remove", "repo", "result_file", "retry_attempts", "retry_delay",
+	"retry_max_delay", "secret", "secrets", "security_opts", "shm_sizes",
+	"squash", "ssh_components", "stream", "tags", "target", "ulimits", "username",
+}
+
+// add captures the secret values to redact from output.
+//
+// A value spanning multiple lines is also captured line by line
+// since output is redacted one line at a time. Only lines long
+// enough to be secret on their own are captured.
+func (r *redactor) add(values ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, value := range values {
+		r.capture(strings.TrimSpace(value))
+
+		// check if the value spans multiple lines
+		if !strings.Contains(value, "\n") {
+			continue
+		}
+
+		for _, line := range strings.Split(value, "\n") {
+			r.capture(secretLine(line))
+		}
+	}
+
+	slices.SortStableFunc(r.values, func(a, b string) int {
+		return len(b) - len(a)
+	})
+}
+
+// secretLine returns the part of a line of a multi-line secret value which
+// is secret on its own or an empty string when the line is only structure.
+//
+// The value of a field in a JSON object is returned without the field name.
+func secretLine(line string) string {
+	line = strings.TrimSpace(line)
+
+	// check if the line is a field of a JSON object
+	if m := jsonFieldRegex.FindStringSubmatch(line); m != nil {
+		line = m[1]
+	}
+
+	line = strings.Trim(strings.TrimSuffix(line, ","), `"`)
+
+	// check if the line is too short or only structure of the value
+	if len(line) < redactLineLength || slices.Contains(structuralTokens, line) {
+		return ""
+	}
+
+	return line
+}
+
+// capture captures the secret value if it is long enough
+// to be secret and not already captured.
+//
+// The caller must hold the lock for the redactor.
+func (r *redactor) capture(value string) {
+	// check if the value is too short, only structure or already captured
+	if len(value) < redactMinLength || slices.Contains(structuralTokens, value) || slices.Contains(r.values, value) {
+		return
+	}
+
+	r.values = append(r.values, value)
+}
+
+// redact replaces the secret values and the passwords
+// of URLs with embedded credentials in the string.
+func (r *redactor) redact(s string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, v := range r.values {
+		s = strings.ReplaceAll(s, v, constants.SecretMask)
+	}
+
+	return urlCredentialsRegex.ReplaceAllString(s, "${1}"+constants.SecretMask+"@")
+}
+
+// writer returns a writer that redacts the output one line
+// at a time before writing it to the provided writer.
+//
+// The returned writer must be flushed once the output is complete.
+func (r *redactor) writer(out io.Writer) *redactWriter {
+	return &redactWriter{redactor: r, out: out}
+}
+
+// redactWriter redacts secret values from complete lines before writing them.
+type redactWriter struct {
+	redactor *redactor
+	out      io.Writer
+	partial  []byte
+}
+
+// Write buffers the output until a line is complete and writes
+// the redacted line to the underlying writer.
+func (w *redactWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+
+	for {
+		// progress output rewrites a line with a carriage return
+		i := bytes.IndexAny(w.partial, "\r\n")
+		if i < 0 {
+			break
+		}
+
+		_, err := io.WriteString(w.out, w.redactor.redact(string(w.partial[:i]))+string(w.partial[i]))
+		if err != nil {
+			return 0, err
+		}
+
+		w.partial = w.partial[i+1:]
+	}
+
+	return len(p), nil
+}
+
+// Flush writes the redacted remainder of an incomplete line.
+func (w *redactWriter) Flush() error {
+	// check if there is any remaining output
+	if len(w.partial) == 0 {
+		return nil
+	}
+
+	_, err := io.WriteString(w.out, w.redactor.redact(string(w.partial)))
+
+	w.partial = nil
+
+	return err
+}
+
+// redactFormatter redacts secret values from the log entries.
+type redactFormatter struct {
+	logrus.Formatter
+}
+
+// Format formats the log entry with the underlying formatter and redacts it.
+func (f *redactFormatter) Format(e *logrus.Entry) ([]byte, error) {
+	out, err := f.Formatter.Format(e)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(secretMask.redact(string(out))), nil
+}
+
+// loadSecretFiles captures the values of the Vela secrets mounted for
+// the plugin in the provided directory except for the parameterFiles.
+func (r *redactor) loadSecretFiles(dir string) {
+	// use custom filesystem which enables us to test
+	a := &afero.Afero{
+		Fs: appFS,
+	}
+
+	entries, err := a.ReadDir(dir)
+	if err != nil {
+		// check if any secrets are mounted for the plugin
+		if !errors.Is(err, fs.ErrNotExist) {
+			logrus.Warnf("unable to read secrets in %s for redaction: %v", dir, err)
+		}
+
+		return
+	}
+
+	for _, entry := range entries {
+		// check if the entry is a parameter which only configures the plugin
+		if entry.IsDir() || slices.Contains(parameterFiles, entry.Name()) {
+			continue
+		}
+
+		data, err := a.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			logrus.Warnf("unable to read secret %s for redaction: %v", entry.Name(), err)
+
+			continue
+		}
+
+		r.add(string(data))
+	}
+}
@@ -0,0 +1,222 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxNameLength is the maximum length of the
+// registry and path of an image reference.
+const maxNameLength = 255
+
+var (
+	// domainRegex represents the valid syntax for the registry host of a reference.
+	domainRegex = regexp.MustCompile(`^(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)*|\[[0-9a-fA-F:]+\])(?::[0-9]+)?$`)
+
+	// pathRegex represents the valid syntax for the repository path of a reference.
+	pathRegex = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*$`)
+
+	// tagRegex represents the valid syntax for the tag of a reference.
+	tagRegex = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+
+	// digestRegex represents the valid syntax for the digest of a reference.
+	digestRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,}$`)
+
+	// dockerHub represents the names of the Docker Hub registry which
+	// are left off of references since Docker defaults to them.
+	dockerHub = map[string]bool{
+		"docker.io":               true,
+		"index.docker.io":         true,
+		"registry-1.docker.io":    true,
+		"registry.hub.docker.com": true,
+	}
+)
+
+// authHost returns the host the authentication for the registry is stored with.
+func authHost(host string) string {
+	// default to Docker Hub when no registry is provided
+	if len(host) == 0 || dockerHub[host] {
+		return "index.docker.io"
+	}
+
+	return host
+}
+
+// Reference represents the components of a Docker image reference
+// in the format of [registry/]path[:tag][@digest].
+type Reference struct {
+	// registry host of the image (e.g. index.docker.io)
+	Registry string
+	// repository path of the image (e.g. octocat/hello-world)
+	Path string
+	// tag of the image (e.g. latest)
+	Tag string
+	// digest of the image (e.g. sha256:...)
+	Digest string
+}
+
+// ParseReference captures the components of the provided
+// image reference and verifies each has a valid syntax.
+func ParseReference(s string) (*Reference, error) {
+	r := new(Reference)
+
+	name := s
+
+	// check if a digest is provided
+	if n, digest, ok := strings.Cut(name, "@"); ok {
+		if !digestRegex.MatchString(digest) {
+			return nil, fmt.Errorf("invalid reference %q: invalid digest %q", s, digest)
+		}
+
+		name, r.Digest = n, digest
+	}
+
+	// check if a tag is provided after the last path component
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		if !tagRegex.MatchString(name[i+1:]) {
+			return nil, fmt.Errorf("invalid reference %q: invalid tag %q", s, name[i+1:])
+		}
+
+		name, r.Tag = name[:i], name[i+1:]
+	}
+
+	// check if the first path component is a registry host
+	if domain, path, ok := strings.Cut(name, "/"); ok && isDomain(domain) {
+		if !domainRegex.MatchString(domain) {
+			return nil, fmt.Errorf("invalid reference %q: invalid registry %q", s, domain)
+		}
+
+		r.Registry, name = domain, path
+	}
+
+	// verify the repository path is valid
+	if !pathRegex.MatchString(name) {
+		return nil, fmt.Errorf("invalid reference %q: invalid repository %q", s, name)
+	}
+
+	r.Path = name
+
+	// verify the name is not too long
+	if len(r.Name()) > maxNameLength {
+		return nil, fmt.Errorf("invalid reference %q: name exceeds %d characters", s, maxNameLength)
+	}
+
+	return r, nil
+}
+
+// Name returns the registry and repository path of the reference.
+func (r *Reference) Name() string {
+	// check if a registry is provided
+	if len(r.Registry) == 0 {
+		return r.Path
+	}
+
+	return r.Registry + "/" + r.Path
+}
+
+// String returns the reference in the format of [registry/]path[:tag][@digest].
+func (r *Reference) String() string {
+	s := r.Name()
+
+	// check if a tag is provided
+	if len(r.Tag) > 0 {
+		s += ":" + r.Tag
+	}
+
+	// check if a digest is provided
+	if len(r.Digest) > 0 {
+		s += "@" + r.Digest
+	}
+
+	return s
+}
+
+// resolveTags normalizes every tag against the registry and repository.
+func resolveTags(tags []string, registry, repo string) ([]string, error) {
+	logrus.Trace("resolving tags against the registry and repository")
+
+	// check if a Docker repository was provided
+	if len(repo) > 0 {
+		r, err := ParseReference(repo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid repo: %w", err)
+		}
+
+		// verify the repository does not contain a tag or digest
+		if len(r.Tag) > 0 || len(r.Digest) > 0 {
+			return nil, fmt.Errorf("invalid repo %q: must not contain a tag or digest", repo)
+		}
+	}
+
+	// variable to store the resolved tags
+	resolved := make([]string, 0, len(tags))
+
+	for _, t := range tags {
+		r, err := resolveTag(t, registry, repo)
+		if err != nil {
+			return nil, err
+		}
+
+		resolved = append(resolved, r.String())
+	}
+
+	return resolved, nil
+}
+
+// resolveTag normalizes the tag against the registry and repository.
+//
+// A tag without a repository (e.g. latest) is prefixed with the repository
+// when one is provided. A reference without a registry host is prefixed
+// with the registry unless the registry is Docker Hub.
+func resolveTag(tag, registry, repo string) (*Reference, error) {
+	name := tag
+
+	// check if the tag needs to be prefixed with the repository
+	if len(repo) > 0 && tagRegex.MatchString(tag) {
+		name = fmt.Sprintf("%s:%s", repo, tag)
+	}
+
+	r, err := ParseReference(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag: %w", err)
+	}
+
+	// verify the reference does not contain a digest
+	if len(r.Digest) > 0 {
+		return nil, fmt.Errorf("invalid tag %q: must not contain a digest", tag)
+	}
+
+	// check if the reference needs to be prefixed with the registry
+	if len(r.Registry) == 0 {
+		host := registryHost(registry)
+
+		if len(host) > 0 && !dockerHub[host] {
+			r.Registry = host
+		}
+	}
+
+	return r, nil
+}
+
+// registryHost returns the host from the registry name.
+func registryHost(registry string) string {
+	// remove the scheme from the registry
+	if _, host, ok := strings.Cut(registry, "://"); ok {
+		registry = host
+	}
+
+	// remove the path from the registry
+	host, _, _ := strings.Cut(registry, "/")
+
+	return host
+}
+
+// isDomain returns true when the path component is a registry host.
+func isDomain(s string) bool {
+	return strings.ContainsAny(s, ".:[") || s == "localhost" || strings.ToLower(s) != s
+}
@@ -0,0 +1,305 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDocker_ParseReference(t *testing.T) {
+	// setup types
+	digest := "sha256:" + strings.Repeat("a", 64)
+
+	// setup tests
+	tests := []struct {
+		failure bool
+		ref     string
+		want    *Reference
+	}{
+		{
+			failure: false,
+			ref:     "alpine",
+			want:    &Reference{Path: "alpine"},
+		},
+		{
+			failure: false,
+			ref:     "alpine:3.22",
+			want:    &Reference{Path: "alpine", Tag: "3.22"},
+		},
+		{
+			failure: false,
+			ref:     "octocat/hello-world:latest",
+			want:    &Reference{Path: "octocat/hello-world", Tag: "latest"},
+		},
+		{
+			failure: false,
+			ref:     "index.docker.io/octocat/hello-world:latest",
+			want:    &Reference{Registry: "index.docker.io", Path: "octocat/hello-world", Tag: "latest"},
+		},
+		{
+			failure: false,
+			ref:     "localhost/hello-world",
+			want:    &Reference{Registry: "localhost", Path: "hello-world"},
+		},
+		{
+			failure: false,
+			ref:     "localhost:5000/octocat/hello-world:1.2.3",
+			want:    &Reference{Registry: "localhost:5000", Path: "octocat/hello-world", Tag: "1.2.3"},
+		},
+		{
+			failure: false,
+			ref:     "[::1]:5000/hello-world:v1",
+			want:    &Reference{Registry: "[::1]:5000", Path: "hello-world", Tag: "v1"},
+		},
+		{
+			failure: false,
+			ref:     "us-docker.pkg.dev/project/repo/hello_world__v2/app",
+			want:    &Reference{Registry: "us-docker.pkg.dev", Path: "project/repo/hello_world__v2/app"},
+		},
+		{
+			failure: false,
+			ref:     "ghcr.io/octocat/hello-world@" + digest,
+			want:    &Reference{Registry: "ghcr.io", Path: "octocat/hello-world", Digest: digest},
+		},
+		{
+			failure: false,
+			ref:     "ghcr.io/octocat/hello-world:latest@" + digest,
+			want:    &Reference{Registry: "ghcr.io", Path: "octocat/hello-world", Tag: "latest", Digest: digest},
+		},
+		{
+			failure: true,
+			ref:     "",
+		},
+		{
+			failure: true,
+			ref:     "octocat/Hello-World",
+		},
+		{
+			failure: true,
+			ref:     "octocat/hello-world:",
+		},
+		{
+			failure: true,
+			ref:     "octocat/hello-world:-latest",
+		},
+		{
+			failure: true,
+			ref:     "octocat/hello-world:" + strings.Repeat("a", 129),
+		},
+		{
+			failure: true,
+			ref:     "octocat/hello-world@sha256:abc",
+		},
+		{
+			failure: true,
+			ref:     "octocat//hello-world",
+		},
+		{
+			failure: true,
+			ref:     "octocat/hello-world/",
+		},
+		{
+			failure: true,
+			ref:     "-registry.io/hello-world",
+		},
+		{
+			failure: true,
+			ref:     "octocat/" + strings.Repeat("a", 255),
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got, err := ParseReference(test.ref)
+
+		if test.failure {
+			if err == nil {
+				t.Errorf("ParseReference should have returned err for %q", test.ref)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("ParseReference returned err: %v", err)
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseReference is %+v, want %+v", got, test.want)
+		}
+
+		if got.String() != test.ref {
+			t.Errorf("String is %s, want %s", got.String(), test.ref)
+		}
+	}
+}
+
+func TestDocker_resolveTag(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		failure  bool
+		tag      string
+		registry string
+		repo     string
+		want     string
+	}{
+		{
+			failure:  false,
+			tag:      "latest",
+			registry: "index.docker.io",
+			repo:     "octocat/hello-world",
+			want:     "octocat/hello-world:latest",
+		},
+		{
+			failure:  false,
+			tag:      "1.2.3",
+			registry: "index.docker.io",
+			repo:     "octocat/hello-world",
+			want:     "octocat/hello-world:1.2.3",
+		},
+		{
+			failure:  false,
+			tag:      "octocat/hello-world:1",
+			registry: "index.docker.io",
+			repo:     "octocat/hello-world",
+			want:     "octocat/hello-world:1",
+		},
+		{
+			failure:  false,
+			tag:      "index.docker.io/octocat/hello-world:foobar",
+			registry: "index.docker.io",
+			repo:     "octocat/hello-world",
+			want:     "index.docker.io/octocat/hello-world:foobar",
+		},
+		{
+			// tag containing the repo name as a substring
+			failure:  false,
+			tag:      "octocat/hello-world-extras:1",
+			registry: "index.docker.io",
+			repo:     "octocat/hello-world",
+			want:     "octocat/hello-world-extras:1",
+		},
+		{
+			// repo name appearing within the tag itself
+			failure:  false,
+			tag:      "hello-world",
+			registry: "index.docker.io",
+			repo:     "hello-world",
+			want:     "hello-world:hello-world",
+		},
+		{
+			failure:  false,
+			tag:      "latest",
+			registry: "ghcr.io",
+			repo:     "octocat/hello-world",
+			want:     "ghcr.io/octocat/hello-world:latest",
+		},
+		{
+			failure:  false,
+			tag:      "latest",
+			registry: "https://ghcr.io/v2/",
+			repo:     "octocat/hello-world",
+			want:     "ghcr.io/octocat/hello-world:latest",
+		},
+		{
+			failure:  false,
+			tag:      "latest",
+			registry: "ghcr.io",
+			repo:     "registry.example.com/octocat/hello-world",
+			want:     "registry.example.com/octocat/hello-world:latest",
+		},
+		{
+			failure:  false,
+			tag:      "octocat/hello-world:latest",
+			registry: "localhost:5000",
+			want:     "localhost:5000/octocat/hello-world:latest",
+		},
+		{
+			failure:  false,
+			tag:      "octocat/hello-world:latest",
+			registry: "docker.io",
+			want:     "octocat/hello-world:latest",
+		},
+		{
+			failure:  false,
+			tag:      "octocat/hello-world",
+			registry: "index.docker.io",
+			want:     "octocat/hello-world",
+		},
+		{
+			failure:  true,
+			tag:      "octocat/hello-world@sha256:" + strings.Repeat("a", 64),
+			registry: "index.docker.io",
+		},
+		{
+			failure:  true,
+			tag:      "feature/Foo",
+			registry: "index.docker.io",
+			repo:     "octocat/hello-world",
+		},
+		{
+			failure:  true,
+			tag:      "foo bar",
+			registry: "index.docker.io",
+			repo:     "octocat/hello-world",
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got, err := resolveTag(test.tag, test.registry, test.repo)
+
+		if test.failure {
+			if err == nil {
+				t.Errorf("resolveTag should have returned err for %q", test.tag)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("resolveTag returned err: %v", err)
+
+			continue
+		}
+
+		if got.String() != test.want {
+			t.Errorf("resolveTag is %s, want %s", got, test.want)
+		}
+	}
+}
+
+func TestDocker_resolveTags(t *testing.T) {
+	// setup types
+	tags := []string{"latest", "octocat/hello-world:1", "index.docker.io/octocat/hello-world:foobar"}
+
+	want := []string{
+		"octocat/hello-world:latest",
+		"octocat/hello-world:1",
+		"index.docker.io/octocat/hello-world:foobar",
+	}
+
+	got, err := resolveTags(tags, "index.docker.io", "octocat/hello-world")
+	if err != nil {
+		t.Errorf("resolveTags returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("resolveTags is %v, want %v", got, want)
+	}
+}
+
+func TestDocker_resolveTags_InvalidRepo(t *testing.T) {
+	// setup tests
+	repos := []string{"octocat/Hello-World", "octocat/hello-world:latest"}
+
+	// run tests
+	for _, repo := range repos {
+		_, err := resolveTags([]string{"latest"}, "index.docker.io", repo)
+		if err == nil {
+			t.Errorf("resolveTags should have returned err for %s", repo)
+		}
+	}
+}
@@ -5,43 +5,63 @@ package main
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/afero"
 	"github.com/urfave/cli/v3"
-
-	"github.com/go-vela/server/constants"
 )
 
 const (
 	credentials = `%s:%s`
 
-	registryFile = `{
-  "auths": {
-    "%s": {
-      "auth": "%s"
-    }
-  }
-}`
-
 	loginAction = "login"
 )
 
-// Registry represents the input parameters for the plugin.
-type Registry struct {
-	// enable building the image without publishing
-	DryRun bool
-	// full url to Docker Registry
-	Name string
-	// password for communication with the Docker Registry
-	Password string
-	// user name for communication with the Docker Registry
-	Username string
-}
+type (
+	// Registry represents the input parameters for the plugin.
+	Registry struct {
+		// used for exchanging credentials for short-lived registry tokens
+		Credential *Credential
+		// enables authenticating with a registry-native credential helper - options (ecr|gcr|acr)
+		CredentialHelper string `json:"credential_helper"`
+		// used for running the login against the daemon
+		Engine Engine `json:"-"`
+		// enable building the image without publishing
+		DryRun bool `json:"-"`
+		// full url to Docker Registry
+		Name string
+		// password for communication with the Docker Registry
+		Password string
+		// used for translating the additional registries to authenticate with
+		Registries []*Registry `json:"-"`
+		// enables setting additional registries to authenticate with
+		RegistriesRaw string `json:"-"`
+		// enables retrying the login when it fails with a transient error
+		Retry *Retry `json:"-"`
+		// user name for communication with the Docker Registry
+		Username string
+	}
+
+	// config represents the Docker config file for authenticating with registries.
+	config struct {
+		// authentication for each registry keyed by the registry name
+		Auths map[string]*auth `json:"auths"`
+		// credential helper for each registry keyed by the registry name
+		CredHelpers map[string]string `json:"credHelpers,omitempty"`
+	}
+
+	// auth represents the authentication for a registry within the Docker config file.
+	auth struct {
+		// basic authentication for the registry
+		Auth string `json:"auth"`
+	}
+)
 
 var (
 	// appFs represents a instance of the filesystem.
@@ -59,6 +79,16 @@ var (
 				cli.File("/vela/secrets/docker/dry_run"),
 			),
 		},
+		&cli.StringFlag{
+			Name:  "registry.credential-helper",
+			Usage: "enables authenticating with a registry-native credential helper - options (ecr|gcr|acr)",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_CREDENTIAL_HELPER"),
+				cli.EnvVar("DOCKER_CREDENTIAL_HELPER"),
+				cli.File("/vela/parameters/docker/credential_helper"),
+				cli.File("/vela/secrets/docker/credential_helper"),
+			),
+		},
 		&cli.StringFlag{
 			Name:  "registry.name",
 			Usage: "Docker registry address to communicate with",
@@ -80,6 +110,16 @@ var (
 				cli.File("/vela/secrets/docker/password"),
 			),
 		},
+		&cli.StringFlag{
+			Name:  "registry.registries",
+			Usage: "additional registries to authenticate with for pulling or publishing images",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_REGISTRIES"),
+				cli.EnvVar("DOCKER_REGISTRIES"),
+				cli.File("/vela/parameters/docker/registries"),
+				cli.File("/vela/secrets/docker/registries"),
+			),
+		},
 		&cli.StringFlag{
 			Name:  "registry.username",
 			Usage: "user name for communication with the registry",
@@ -91,9 +131,6 @@ var (
 			),
 		},
 	}
-
-	// configPath represents the location of the Docker config file for setting registries.
-	configPath = "/root/config.json"
 )
 
 // Write creates a Docker config.json file for building and publishing the image.
@@ -105,38 +142,137 @@ func (r *Registry) Write() error {
 		Fs: appFS,
 	}
 
-	// create basic authentication string for config.json file
-	basicAuth := base64.StdEncoding.EncodeToString(
-		[]byte(fmt.Sprintf(credentials, r.Username, r.Password)),
-	)
+	// create the config.json file contents
+	c := &config{
+		Auths:       make(map[string]*auth),
+		CredHelpers: make(map[string]string),
+	}
+
+	// iterate through the registries provided
+	for _, reg := range append([]*Registry{r}, r.Registries...) {
+		// check if the credential helper binary is available
+		if helper, ok := reg.helper(); ok {
+			// defer to the credential helper for the registry
+			c.CredHelpers[reg.Name] = helper
+
+			continue
+		}
+
+		// create basic authentication string for config.json file
+		c.Auths[reg.Name] = &auth{
+			Auth: base64.StdEncoding.EncodeToString(
+				[]byte(fmt.Sprintf(credentials, reg.Username, reg.Password)),
+			),
+		}
+	}
 
 	// create output string for config.json file
-	out := fmt.Sprintf(
-		registryFile,
-		r.Name,
-		basicAuth,
-	)
+	out, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
 
-	return a.WriteFile(configPath, []byte(out), 0644)
+	path := dockerConfigPath()
+
+	err = a.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+
+	return a.WriteFile(path, out, 0600)
 }
 
-// Login attempts to authenticate with the registry.
+// dockerConfigPath returns the location of the Docker config file read by
+// the docker CLI from the DOCKER_CONFIG directory or the home of the user.
+//
+// The home of the user is used for the rootless daemon since the step
+// runs as the user the daemon is started for.
+func dockerConfigPath() string {
+	// check if the config directory is provided
+	if dir := os.Getenv("DOCKER_CONFIG"); len(dir) > 0 {
+		return filepath.Join(dir, "config.json")
+	}
+
+	return filepath.Join(homeDir(), ".docker", "config.json")
+}
+
+// Exchange trades the cloud credentials of any registry using a
+// credential helper for a short-lived token to authenticate with.
+func (r *Registry) Exchange(ctx context.Context) error {
+	// iterate through the registries provided
+	for _, reg := range append([]*Registry{r}, r.Registries...) {
+		// check if the registry uses a credential helper
+		if len(reg.CredentialHelper) == 0 {
+			continue
+		}
+
+		// check if dry run is enabled for the registry
+		if reg.DryRun {
+			continue
+		}
+
+		// check if the credential helper binary is available
+		if helper, ok := reg.helper(); ok {
+			logrus.Infof("using docker-credential-%s for registry %s", helper, reg.Name)
+
+			continue
+		}
+
+		username, password, err := reg.Credential.Exchange(ctx, reg.CredentialHelper, reg.Name)
+		if err != nil {
+			return err
+		}
+
+		reg.Username = username
+		reg.Password = password
+
+		// redact the short-lived token from the output
+		secretMask.add(password)
+	}
+
+	return nil
+}
+
+// Login attempts to authenticate with the registry
+// and any additional registries provided.
 func (r *Registry) Login(ctx context.Context) error {
 	// check if dry run is enabled
 	if r.DryRun {
 		logrus.Warning("dry_run enabled - skipping authentication with registry")
+	} else {
+		err := r.login(ctx, r.Engine, r.Retry)
+		if err != nil {
+			return err
+		}
+	}
 
-		// skip authenticating with the registry since dry run is enabled
-		return nil
+	// iterate through the additional registries provided
+	//
+	// these are authenticated with even when dry run is enabled
+	// since they may be needed for pulling images during the build
+	for _, reg := range r.Registries {
+		err := reg.login(ctx, r.Engine, r.Retry)
+		if err != nil {
+			return err
+		}
 	}
 
-	logrus.Trace("authenticating with registry")
+	return nil
+}
+
+// Command formats and outputs the Login command from
+// the provided configuration to authenticate with the registry.
+//
+// The password is provided to the command over stdin to prevent
+// it from being visible in the arguments of the process.
+func (r *Registry) Command(ctx context.Context) *exec.Cmd {
+	logrus.Trace("creating docker login command from plugin configuration")
 
 	// variable to store flags for command
 	var flags []string
 
-	// add flag for registry password
-	flags = append(flags, "--password", r.Password)
+	// add flag for reading the registry password from stdin
+	flags = append(flags, "--password-stdin")
 
 	// add flag for registry username
 	flags = append(flags, "--username", r.Username)
@@ -144,20 +280,87 @@ func (r *Registry) Login(ctx context.Context) error {
 	// add flag for registry name
 	flags = append(flags, r.Name)
 
-	//nolint: gosec // ignore executing command as subprocess
+	//nolint:gosec // ignore executing command as subprocess
 	e := exec.CommandContext(ctx, _docker, append([]string{loginAction}, flags...)...)
 
-	// set command stdout to OS stdout
-	e.Stdout = os.Stdout
-	// set command stderr to OS stderr
-	e.Stderr = os.Stderr
+	// set command stdin to the registry password
+	e.Stdin = strings.NewReader(r.Password)
 
-	// replace the plain-test password with masking for security purposes
-	cmd := strings.ReplaceAll(strings.Join(e.Args, " "), r.Password, constants.SecretMask)
+	return e
+}
 
-	fmt.Println("$", cmd)
+// login authenticates with the registry using
+// the provided engine and retry settings.
+func (r *Registry) login(ctx context.Context, engine Engine, retry *Retry) error {
+	// check if the credential helper binary is available
+	if _, ok := r.helper(); ok {
+		logrus.Tracef("skipping authentication with registry %s using credential helper", r.Name)
 
-	return e.Run()
+		return nil
+	}
+
+	logrus.Tracef("authenticating with registry %s", r.Name)
+
+	return engineOrCLI(engine).Login(ctx, r, retry)
+}
+
+// helper returns the name of the credential helper binary
+// for the registry if one is configured and available.
+func (r *Registry) helper() (string, bool) {
+	// check if the registry uses a credential helper
+	if len(r.CredentialHelper) == 0 {
+		return "", false
+	}
+
+	return credentialHelper(r.CredentialHelper)
+}
+
+// secrets returns the secret values for the registry and any additional registries.
+func (r *Registry) secrets() []string {
+	// variable to store the secret values
+	var values []string
+
+	for _, reg := range append([]*Registry{r}, r.Registries...) {
+		values = append(values, reg.Password)
+
+		// check if credentials are provided for exchanging tokens
+		if reg.Credential != nil {
+			values = append(values, reg.Credential.secrets()...)
+		}
+	}
+
+	return values
+}
+
+// authenticates returns true when credentials are provided for the registry host.
+func (r *Registry) authenticates(host string) bool {
+	for _, reg := range append([]*Registry{r}, r.Registries...) {
+		name := registryHost(reg.Name)
+
+		// check if the registry matches the host
+		if name == host || (dockerHub[name] && dockerHub[host]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Unmarshal captures the provided properties and
+// serializes them into their expected form.
+func (r *Registry) Unmarshal() error {
+	logrus.Trace("unmarshaling registry options")
+
+	// check if any additional registries were passed
+	if len(r.RegistriesRaw) > 0 {
+		// serialize raw registries into expected Registry type
+		err := json.Unmarshal([]byte(r.RegistriesRaw), &r.Registries)
+		if err != nil {
+			return fmt.Errorf("unable to unmarshal registries: %w", err)
+		}
+	}
+
+	return nil
 }
 
 // Validate verifies the Registry is properly configured.
@@ -171,16 +374,67 @@ func (r *Registry) Validate() error {
 
 	// check if dry run is disabled
 	if !r.DryRun {
+		// check if a credential helper is provided
+		if len(r.CredentialHelper) > 0 {
+			err := r.validateHelper()
+			if err != nil {
+				return err
+			}
+		} else {
+			// check if username is provided
+			if len(r.Username) == 0 {
+				return fmt.Errorf("no registry username provided")
+			}
+
+			// check if password is provided
+			if len(r.Password) == 0 {
+				return fmt.Errorf("no registry password provided")
+			}
+		}
+	}
+
+	// iterate through the additional registries provided
+	for i, reg := range r.Registries {
+		// check if name is provided
+		if len(reg.Name) == 0 {
+			return fmt.Errorf("no name provided for registries[%d]", i)
+		}
+
+		// check if a credential helper is provided
+		if len(reg.CredentialHelper) > 0 {
+			err := reg.validateHelper()
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
 		// check if username is provided
-		if len(r.Username) == 0 {
-			return fmt.Errorf("no registry username provided")
+		if len(reg.Username) == 0 {
+			return fmt.Errorf("no username provided for registry %s", reg.Name)
 		}
 
 		// check if password is provided
-		if len(r.Password) == 0 {
-			return fmt.Errorf("no registry password provided")
+		if len(reg.Password) == 0 {
+			return fmt.Errorf("no password provided for registry %s", reg.Name)
 		}
 	}
 
 	return nil
 }
+
+// validateHelper verifies the credential helper for the registry is properly configured.
+func (r *Registry) validateHelper() error {
+	// check if the credential helper is supported
+	if _, ok := credentialHelpers[r.CredentialHelper]; !ok {
+		return fmt.Errorf("invalid credential_helper %s for registry %s: expected one of ecr, gcr or acr", r.CredentialHelper, r.Name)
+	}
+
+	// check if the credential helper binary is available
+	if _, ok := r.helper(); ok {
+		return nil
+	}
+
+	return r.Credential.Validate(r.CredentialHelper, r.Name)
+}
@@ -3,11 +3,125 @@
 package main
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/afero"
 )
 
+func TestDocker_Registry_Command(t *testing.T) {
+	// setup types
+	r := &Registry{
+		Name:     "index.docker.io",
+		Username: "octocat",
+		Password: "superSecretPassword",
+	}
+
+	want := exec.CommandContext(
+		t.Context(),
+		_docker,
+		loginAction,
+		"--password-stdin",
+		"--username", r.Username,
+		r.Name,
+	)
+
+	got := r.Command(t.Context())
+	if got.String() != want.String() {
+		t.Errorf("Command is %v, want %v", got, want)
+	}
+
+	// verify the password is only provided over stdin
+	if strings.Contains(got.String(), r.Password) {
+		t.Errorf("Command %v contains the registry password", got)
+	}
+
+	stdin, err := io.ReadAll(got.Stdin)
+	if err != nil {
+		t.Errorf("ReadAll returned err: %v", err)
+	}
+
+	if string(stdin) != r.Password {
+		t.Errorf("Command stdin is %s, want %s", stdin, r.Password)
+	}
+}
+
+func TestDocker_Registry_Login_NoPasswordOutput(t *testing.T) {
+	// setup types
+	r := &Registry{
+		Name:     "index.docker.io",
+		Username: "octocat",
+		Password: "superSecretPassword",
+		Registries: []*Registry{
+			{
+				Name:     "ghcr.io",
+				Username: "hubot",
+				Password: "anotherSecretPassword",
+			},
+		},
+	}
+
+	// capture the log output at the most verbose level
+	logs := new(bytes.Buffer)
+
+	level := logrus.GetLevel()
+	logrus.SetOutput(logs)
+	logrus.SetLevel(logrus.TraceLevel)
+
+	t.Cleanup(func() {
+		logrus.SetOutput(os.Stderr)
+		logrus.SetLevel(level)
+	})
+
+	// capture the output written to stdout
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Pipe returned err: %v", err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = writer
+
+	// attempt to authenticate with every registry
+	_ = r.Login(t.Context())
+	_ = r.Registries[0].Login(t.Context())
+
+	os.Stdout = stdout
+
+	writer.Close()
+
+	out, err := io.ReadAll(reader)
+	if err != nil {
+		t.Errorf("ReadAll returned err: %v", err)
+	}
+
+	for _, password := range []string{r.Password, r.Registries[0].Password} {
+		if strings.Contains(logs.String(), password) {
+			t.Errorf("Login logged the registry password: %s", logs.String())
+		}
+
+		if strings.Contains(string(out), password) {
+			t.Errorf("Login output the registry password: %s", out)
+		}
+	}
+
+	if !strings.Contains(logs.String(), "executing cmd") {
+		t.Errorf("Login did not trace the executed commands: %s", logs.String())
+	}
+}
+
 func TestDocker_Registry_Login(t *testing.T) {
 	// setup tests
 	tests := []struct {
@@ -32,6 +146,20 @@ func TestDocker_Registry_Login(t *testing.T) {
 				DryRun:   false,
 			},
 		},
+		{
+			failure: true,
+			registry: &Registry{
+				Name:   "index.docker.io",
+				DryRun: true,
+				Registries: []*Registry{
+					{
+						Name:     "ghcr.io",
+						Username: "octocat",
+						Password: "superSecretPassword",
+					},
+				},
+			},
+		},
 	}
 
 	// run tests
@@ -52,6 +180,74 @@ func TestDocker_Registry_Login(t *testing.T) {
 	}
 }
 
+func TestDocker_Registry_Login_Retry(t *testing.T) {
+	// setup types
+	count := fakeDocker(t, 2, "Error response from daemon: Get https://ghcr.io/v2/: net/http: TLS handshake timeout", "Login Succeeded")
+
+	r := &Registry{
+		Name:   "index.docker.io",
+		DryRun: true,
+		Registries: []*Registry{
+			{
+				Name:     "ghcr.io",
+				Username: "octocat",
+				Password: "superSecretPassword",
+			},
+		},
+		Retry: &Retry{
+			Attempts: 3,
+			Delay:    time.Millisecond,
+		},
+	}
+
+	err := r.Login(t.Context())
+	if err != nil {
+		t.Errorf("Login returned err: %v", err)
+	}
+
+	if got := attempts(t, count); got != "3" {
+		t.Errorf("Login ran %s attempts, want 3", got)
+	}
+}
+
+func TestDocker_Registry_Unmarshal(t *testing.T) {
+	// setup types
+	r := &Registry{
+		RegistriesRaw: `
+  [{"name": "ghcr.io", "username": "octocat", "password": "superSecretPassword"}]
+`,
+	}
+
+	want := []*Registry{
+		{
+			Name:     "ghcr.io",
+			Username: "octocat",
+			Password: "superSecretPassword",
+		},
+	}
+
+	err := r.Unmarshal()
+	if err != nil {
+		t.Errorf("Unmarshal returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(r.Registries, want) {
+		t.Errorf("Unmarshal is %v, want %v", r.Registries, want)
+	}
+}
+
+func TestDocker_Registry_Unmarshal_Fail(t *testing.T) {
+	// setup types
+	r := &Registry{
+		RegistriesRaw: "!@#$%^&*()",
+	}
+
+	err := r.Unmarshal()
+	if err == nil {
+		t.Errorf("Unmarshal should have returned err")
+	}
+}
+
 func TestDocker_Registry_Validate(t *testing.T) {
 	// setup tests
 	tests := []struct {
@@ -98,6 +294,59 @@ func TestDocker_Registry_Validate(t *testing.T) {
 				DryRun:   false,
 			},
 		},
+		{
+			failure: false,
+			registry: &Registry{
+				Name:   "index.docker.io",
+				DryRun: true,
+				Registries: []*Registry{
+					{
+						Name:     "ghcr.io",
+						Username: "octocat",
+						Password: "superSecretPassword",
+					},
+				},
+			},
+		},
+		{
+			failure: true,
+			registry: &Registry{
+				Name:   "index.docker.io",
+				DryRun: true,
+				Registries: []*Registry{
+					{
+						Username: "octocat",
+						Password: "superSecretPassword",
+					},
+				},
+			},
+		},
+		{
+			failure: true,
+			registry: &Registry{
+				Name:   "index.docker.io",
+				DryRun: true,
+				Registries: []*Registry{
+					{
+						Name:     "ghcr.io",
+						Password: "superSecretPassword",
+					},
+				},
+			},
+		},
+		{
+			failure: true,
+			registry: &Registry{
+				Name:   "index.docker.io",
+				DryRun: true,
+				Registries: []*Registry{
+					{
+						Name:     "ghcr.io",
+						Username: "octocat",
+					},
+				},
+			},
+		},
 	}
 
 	// run tests
@@ -136,6 +385,121 @@ func TestDocker_Registry_Write(t *testing.T) {
 	}
 }
 
+func TestDocker_Registry_Write_Registries(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	r := &Registry{
+		Name:     "index.docker.io",
+		Username: "octocat",
+		Password: "superSecretPassword",
+		Registries: []*Registry{
+			{
+				Name:     "ghcr.io",
+				Username: "hubot",
+				Password: "anotherSecretPassword",
+			},
+		},
+	}
+
+	want := &config{
+		Auths: map[string]*auth{
+			"index.docker.io": {
+				Auth: base64.StdEncoding.EncodeToString([]byte("octocat:superSecretPassword")),
+			},
+			"ghcr.io": {
+				Auth: base64.StdEncoding.EncodeToString([]byte("hubot:anotherSecretPassword")),
+			},
+		},
+	}
+
+	err := r.Write()
+	if err != nil {
+		t.Errorf("Write returned err: %v", err)
+	}
+
+	data, err := afero.ReadFile(appFS, dockerConfigPath())
+	if err != nil {
+		t.Errorf("ReadFile returned err: %v", err)
+	}
+
+	got := new(config)
+
+	err = json.Unmarshal(data, got)
+	if err != nil {
+		t.Errorf("Unmarshal returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Write is %v, want %v", got, want)
+	}
+}
+
+func TestDocker_Registry_Write_ConfigPath(t *testing.T) {
+	// setup types
+	u, err := user.LookupId(strconv.Itoa(os.Geteuid()))
+	if err != nil {
+		t.Fatalf("unable to look up current user: %v", err)
+	}
+
+	// setup tests
+	tests := []struct {
+		name         string
+		dockerConfig string
+		home         string
+		want         string
+	}{
+		{
+			name: "home",
+			home: "/root",
+			want: "/root/.docker/config.json",
+		},
+		{
+			name: "rootless",
+			home: "/home/rootless",
+			want: "/home/rootless/.docker/config.json",
+		},
+		{
+			name: "no home",
+			want: filepath.Join(u.HomeDir, ".docker", "config.json"),
+		},
+		{
+			name:         "docker config",
+			dockerConfig: "/vela/docker",
+			home:         "/home/rootless",
+			want:         "/vela/docker/config.json",
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// setup filesystem
+			appFS = afero.NewMemMapFs()
+
+			t.Setenv("DOCKER_CONFIG", test.dockerConfig)
+			t.Setenv("HOME", test.home)
+
+			r := &Registry{
+				Name:     "index.docker.io",
+				Username: "octocat",
+				Password: "superSecretPassword",
+			}
+
+			err := r.Write()
+			if err != nil {
+				t.Fatalf("Write returned err: %v", err)
+			}
+
+			// verify the config is written where the docker CLI reads it
+			if ok, _ := afero.Exists(appFS, test.want); !ok {
+				t.Errorf("Write should have created %s", test.want)
+			}
+		})
+	}
+}
+
 func TestDocker_Registry_Write_NoName(t *testing.T) {
 	// setup filesystem
 	appFS = afero.NewMemMapFs()
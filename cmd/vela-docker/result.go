@@ -0,0 +1,252 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/urfave/cli/v3"
+)
+
+// metadataDigest is the key of the image digest in the buildx metadata file.
+const metadataDigest = "containerimage.digest"
+
+type (
+	// Result represents the plugin configuration for reporting the published images.
+	Result struct {
+		// enables writing the published images to a JSON file
+		File string
+		// path to the file for setting Vela step outputs
+		Outputs string
+		// published images captured from the push
+		Images []*Image
+	}
+
+	// Image represents an image published by the plugin.
+	Image struct {
+		// tag the image was published with
+		Tag string `json:"tag"`
+		// manifest digest of the published image
+		Digest string `json:"digest"`
+		// size of the manifest of the published image
+		Size int64 `json:"size,omitempty"`
+		// platform(s) of the published image
+		Platform string `json:"platform,omitempty"`
+	}
+)
+
+var (
+	// pushDigestRegex represents the line output by "docker push" with the digest of the image.
+	pushDigestRegex = regexp.MustCompile(`digest: (sha256:[a-f0-9]{64}) size: (\d+)`)
+
+	// resultFlags represents for result settings on the cli.
+	resultFlags = []cli.Flag{
+		&cli.StringFlag{
+			Name:  "result.file",
+			Usage: "enables writing the published images with their digests to a JSON file",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_RESULT_FILE"),
+				cli.EnvVar("DOCKER_RESULT_FILE"),
+				cli.File("/vela/parameters/docker/result_file"),
+				cli.File("/vela/secrets/docker/result_file"),
+			),
+		},
+		&cli.StringFlag{
+			Name:    "result.outputs",
+			Usage:   "path to the file for setting Vela step outputs",
+			Sources: cli.EnvVars("VELA_OUTPUTS"),
+		},
+	}
+)
+
+// add records the image published by the plugin.
+func (r *Result) add(i *Image) {
+	// check if any result settings are provided
+	if r == nil {
+		return
+	}
+
+	r.Images = append(r.Images, i)
+}
+
+// Write creates the result file and the Vela step
+// outputs for the published images when configured.
+func (r *Result) Write() error {
+	// check if any result settings are provided
+	if r == nil {
+		return nil
+	}
+
+	logrus.Trace("writing results for published images")
+
+	// iterate through the published images
+	for _, i := range r.Images {
+		logrus.Infof("published %s@%s", i.Tag, i.Digest)
+	}
+
+	// use custom filesystem which enables us to test
+	a := &afero.Afero{
+		Fs: appFS,
+	}
+
+	// check if a result file is provided
+	if len(r.File) > 0 {
+		images := r.Images
+		if images == nil {
+			images = []*Image{}
+		}
+
+		out, err := json.MarshalIndent(images, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		err = a.WriteFile(r.File, out, 0644)
+		if err != nil {
+			return fmt.Errorf("unable to write result file: %w", err)
+		}
+	}
+
+	// check if Vela step outputs are provided and any images were published
+	if len(r.Outputs) == 0 || len(r.Images) == 0 {
+		return nil
+	}
+
+	f, err := a.OpenFile(r.Outputs, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to open outputs file: %w", err)
+	}
+	defer f.Close()
+
+	_, err = f.WriteString(r.outputs())
+	if err != nil {
+		return fmt.Errorf("unable to write outputs file: %w", err)
+	}
+
+	return nil
+}
+
+// outputs formats the Vela step outputs for the published images.
+func (r *Result) outputs() string {
+	first := r.Images[0]
+
+	// variable to store the tags of the published images
+	tags := make([]string, 0, len(r.Images))
+
+	for _, i := range r.Images {
+		tags = append(tags, i.Tag)
+	}
+
+	// capture the image reference pinned to the digest
+	image := first.Tag + "@" + first.Digest
+
+	ref, err := ParseReference(first.Tag)
+	if err == nil {
+		ref.Tag = ""
+		ref.Digest = first.Digest
+		image = ref.String()
+	}
+
+	return fmt.Sprintf(
+		"DOCKER_DIGEST=%s\nDOCKER_IMAGE=%s\nDOCKER_TAGS=%s\n",
+		first.Digest,
+		image,
+		strings.Join(tags, ","),
+	)
+}
+
+// parseDigest captures the digest and size of the
+// image from the output of the "docker push" command.
+func parseDigest(out string) (string, int64) {
+	matches := pushDigestRegex.FindAllStringSubmatch(out, -1)
+	if len(matches) == 0 {
+		return "", 0
+	}
+
+	// use the last digest output by the push
+	match := matches[len(matches)-1]
+
+	size, err := strconv.ParseInt(match[2], 10, 64)
+	if err != nil {
+		return match[1], 0
+	}
+
+	return match[1], size
+}
+
+// repoDigest returns the digest of the image for the repository of
+// the tag from the repository digests of the image in the daemon.
+func repoDigest(tag string, repoDigests []string) string {
+	ref, err := ParseReference(tag)
+	if err != nil {
+		return ""
+	}
+
+	for _, d := range repoDigests {
+		r, err := ParseReference(d)
+		if err != nil {
+			continue
+		}
+
+		// check if the digest is for the repository of the tag
+		if authHost(r.Registry) == authHost(ref.Registry) && hubPath(r) == hubPath(ref) {
+			return r.Digest
+		}
+	}
+
+	return ""
+}
+
+// hubPath returns the repository path of the reference
+// without the namespace for official Docker Hub images.
+func hubPath(r *Reference) string {
+	// check if the image is hosted on Docker Hub
+	if authHost(r.Registry) != "index.docker.io" {
+		return r.Path
+	}
+
+	return strings.TrimPrefix(r.Path, "library/")
+}
+
+// readMetadata captures the digest of the image
+// from the metadata file written by buildx.
+func readMetadata(path string) (string, error) {
+	// use custom filesystem which enables us to test
+	a := &afero.Afero{
+		Fs: appFS,
+	}
+
+	data, err := a.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read build metadata: %w", err)
+	}
+
+	// variable to store the build metadata
+	metadata := make(map[string]any)
+
+	err = json.Unmarshal(data, &metadata)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse build metadata: %w", err)
+	}
+
+	digest, ok := metadata[metadataDigest].(string)
+	if !ok {
+		return "", fmt.Errorf("no %s found in build metadata", metadataDigest)
+	}
+
+	return digest, nil
+}
+
+// defaultPlatform returns the platform of images
+// built by the daemon when no platform is provided.
+func defaultPlatform() string {
+	return "linux/" + runtime.GOARCH
+}
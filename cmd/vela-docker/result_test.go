@@ -0,0 +1,199 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestDocker_parseDigest(t *testing.T) {
+	// setup types
+	digest := "sha256:" + strings.Repeat("a", 64)
+
+	// setup tests
+	tests := []struct {
+		out    string
+		digest string
+		size   int64
+	}{
+		{
+			out: "The push refers to repository [docker.io/octocat/hello-world]\n" +
+				"5f70bf18a086: Pushed\n" +
+				"latest: digest: " + digest + " size: 528\n",
+			digest: digest,
+			size:   528,
+		},
+		{
+			out: "The push refers to repository [docker.io/octocat/hello-world]\n" +
+				"5f70bf18a086: Layer already exists\n",
+		},
+		{
+			out: "",
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		digest, size := parseDigest(test.out)
+
+		if digest != test.digest || size != test.size {
+			t.Errorf("parseDigest is %s %d, want %s %d", digest, size, test.digest, test.size)
+		}
+	}
+}
+
+func TestDocker_Result_Write(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	digest := "sha256:" + strings.Repeat("a", 64)
+
+	r := &Result{
+		File:    "/vela/result.json",
+		Outputs: "/vela/outputs/.env",
+		Images: []*Image{
+			{
+				Tag:      "ghcr.io/octocat/hello-world:latest",
+				Digest:   digest,
+				Size:     528,
+				Platform: "linux/amd64",
+			},
+			{
+				Tag:      "ghcr.io/octocat/hello-world:1.2.3",
+				Digest:   digest,
+				Size:     528,
+				Platform: "linux/amd64",
+			},
+		},
+	}
+
+	err := r.Write()
+	if err != nil {
+		t.Errorf("Write returned err: %v", err)
+	}
+
+	data, err := afero.ReadFile(appFS, r.File)
+	if err != nil {
+		t.Errorf("ReadFile returned err: %v", err)
+	}
+
+	got := []*Image{}
+
+	err = json.Unmarshal(data, &got)
+	if err != nil {
+		t.Errorf("Unmarshal returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, r.Images) {
+		t.Errorf("Write result file is %v, want %v", got, r.Images)
+	}
+
+	outputs, err := afero.ReadFile(appFS, r.Outputs)
+	if err != nil {
+		t.Errorf("ReadFile returned err: %v", err)
+	}
+
+	want := "DOCKER_DIGEST=" + digest + "\n" +
+		"DOCKER_IMAGE=ghcr.io/octocat/hello-world@" + digest + "\n" +
+		"DOCKER_TAGS=ghcr.io/octocat/hello-world:latest,ghcr.io/octocat/hello-world:1.2.3\n"
+
+	if string(outputs) != want {
+		t.Errorf("Write outputs is %s, want %s", outputs, want)
+	}
+}
+
+func TestDocker_Result_Write_NoImages(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	r := &Result{
+		File:    "/vela/result.json",
+		Outputs: "/vela/outputs/.env",
+	}
+
+	err := r.Write()
+	if err != nil {
+		t.Errorf("Write returned err: %v", err)
+	}
+
+	data, err := afero.ReadFile(appFS, r.File)
+	if err != nil {
+		t.Errorf("ReadFile returned err: %v", err)
+	}
+
+	if string(data) != "[]" {
+		t.Errorf("Write result file is %s, want []", data)
+	}
+
+	exists, _ := afero.Exists(appFS, r.Outputs)
+	if exists {
+		t.Errorf("Write should not have created %s", r.Outputs)
+	}
+}
+
+func TestDocker_readMetadata(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	digest := "sha256:" + strings.Repeat("a", 64)
+
+	// setup tests
+	tests := []struct {
+		failure  bool
+		metadata string
+		want     string
+	}{
+		{
+			failure:  false,
+			metadata: `{"containerimage.digest": "` + digest + `", "image.name": "octocat/hello-world:latest"}`,
+			want:     digest,
+		},
+		{
+			failure:  true,
+			metadata: `{"image.name": "octocat/hello-world:latest"}`,
+		},
+		{
+			failure:  true,
+			metadata: `not json`,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		err := afero.WriteFile(appFS, "/tmp/metadata.json", []byte(test.metadata), 0644)
+		if err != nil {
+			t.Errorf("WriteFile returned err: %v", err)
+		}
+
+		got, err := readMetadata("/tmp/metadata.json")
+
+		if test.failure {
+			if err == nil {
+				t.Errorf("readMetadata should have returned err for %s", test.metadata)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("readMetadata returned err: %v", err)
+		}
+
+		if got != test.want {
+			t.Errorf("readMetadata is %s, want %s", got, test.want)
+		}
+	}
+
+	_, err := readMetadata("/tmp/missing.json")
+	if err == nil {
+		t.Errorf("readMetadata should have returned err for missing file")
+	}
+}
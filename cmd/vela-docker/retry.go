@@ -0,0 +1,219 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v3"
+)
+
+// retryTailLines is the number of lines of stderr
+// captured to classify the error of a failed attempt.
+const retryTailLines = 10
+
+// Retry represents the plugin configuration for retrying commands
+// that communicate with a registry when they fail with a transient error.
+type Retry struct {
+	// enables setting the maximum number of attempts for a command
+	Attempts int
+	// enables setting the delay before the first retry
+	Delay time.Duration
+	// enables setting the maximum delay between retries
+	MaxDelay time.Duration
+}
+
+var (
+	// retryableErrors represents the output from the Docker CLI
+	// for errors that are expected to resolve on their own.
+	retryableErrors = []string{
+		"500 internal server error",
+		"502 bad gateway",
+		"503 service unavailable",
+		"504 gateway timeout",
+		"429 too many requests",
+		"toomanyrequests",
+		"i/o timeout",
+		"tls handshake timeout",
+		"client.timeout exceeded",
+		"context deadline exceeded",
+		"connection reset by peer",
+		"connection refused",
+		"broken pipe",
+		"unexpected eof",
+		"no such host",
+	}
+
+	// permanentErrors represents the output from the Docker CLI
+	// for errors that will not resolve by retrying the command.
+	permanentErrors = []string{
+		"unauthorized",
+		"denied",
+		"authentication required",
+		"incorrect username or password",
+		"name unknown",
+		"manifest invalid",
+		"does not exist",
+		"an image does not exist locally",
+	}
+
+	// retryFlags represents for retry settings on the cli.
+	retryFlags = []cli.Flag{
+		&cli.IntFlag{
+			Name:  "retry.attempts",
+			Usage: "enables setting the maximum number of attempts for registry commands",
+			Value: 3,
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_RETRY_ATTEMPTS"),
+				cli.EnvVar("DOCKER_RETRY_ATTEMPTS"),
+				cli.File("/vela/parameters/docker/retry_attempts"),
+				cli.File("/vela/secrets/docker/retry_attempts"),
+			),
+		},
+		&cli.DurationFlag{
+			Name:  "retry.delay",
+			Usage: "enables setting the delay before the first retry of registry commands",
+			Value: 2 * time.Second,
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_RETRY_DELAY"),
+				cli.EnvVar("DOCKER_RETRY_DELAY"),
+				cli.File("/vela/parameters/docker/retry_delay"),
+				cli.File("/vela/secrets/docker/retry_delay"),
+			),
+		},
+		&cli.DurationFlag{
+			Name:  "retry.max-delay",
+			Usage: "enables setting the maximum delay between retries of registry commands",
+			Value: 30 * time.Second,
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_RETRY_MAX_DELAY"),
+				cli.EnvVar("DOCKER_RETRY_MAX_DELAY"),
+				cli.File("/vela/parameters/docker/retry_max_delay"),
+				cli.File("/vela/secrets/docker/retry_max_delay"),
+			),
+		},
+	}
+)
+
+// Do runs the command created by the provided function until it succeeds,
+// fails with an error that is not retryable or runs out of attempts.
+//
+// A new command is created for every attempt since a command can only be run once.
+func (r *Retry) Do(ctx context.Context, runner Runner, action string, command func() *exec.Cmd) error {
+	return r.Run(ctx, action, func() (string, error) {
+		cmd := command()
+
+		// check if the command stderr is already captured
+		if cmd.Stderr == nil {
+			cmd.Stderr = runnerOrExec(runner).Stderr()
+		}
+
+		// capture the end of the command stderr to classify errors
+		stderr := newTailWriter(retryTailLines)
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, stderr)
+
+		err := execCmd(runner, cmd)
+
+		return stderr.String(), err
+	})
+}
+
+// Run runs the provided attempt until it succeeds, fails with an
+// error that is not retryable or runs out of attempts.
+//
+// The attempt returns the output used to classify the error.
+func (r *Retry) Run(ctx context.Context, action string, attempt func() (string, error)) error {
+	// default to a single attempt when no retry settings are provided
+	retry := &Retry{Attempts: 1}
+	if r != nil && r.Attempts > 1 {
+		retry = r
+	}
+
+	delay := retry.Delay
+
+	for i := 1; ; i++ {
+		// check if more than one attempt is allowed
+		if retry.Attempts > 1 {
+			logrus.Infof("%s: attempt %d of %d", action, i, retry.Attempts)
+		}
+
+		out, err := attempt()
+		if err == nil {
+			return nil
+		}
+
+		// check if the error is expected to resolve on its own
+		if !retryable(out) {
+			return err
+		}
+
+		// check if the attempts have been exhausted
+		if i >= retry.Attempts {
+			return fmt.Errorf("%s failed after %d attempts: %w", action, i, err)
+		}
+
+		logrus.Warnf("%s: attempt %d of %d failed: %v - retrying in %s", action, i, retry.Attempts, err, delay)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s canceled: %w", action, ctx.Err())
+		case <-time.After(delay):
+		}
+
+		// increase the delay exponentially up to the maximum
+		delay *= 2
+		if retry.MaxDelay > 0 && delay > retry.MaxDelay {
+			delay = retry.MaxDelay
+		}
+	}
+}
+
+// Validate verifies the Retry is properly configured.
+func (r *Retry) Validate() error {
+	logrus.Trace("validating retry plugin configuration")
+
+	// check if any retry settings are provided
+	if r == nil {
+		return nil
+	}
+
+	// verify at least one attempt is provided
+	if r.Attempts < 1 {
+		return fmt.Errorf("invalid retry_attempts %d: must be at least 1", r.Attempts)
+	}
+
+	// verify the delays are not negative
+	if r.Delay < 0 || r.MaxDelay < 0 {
+		return fmt.Errorf("invalid retry delay: must not be negative")
+	}
+
+	return nil
+}
+
+// retryable returns true when the stderr of a command
+// contains an error that is expected to resolve on its own.
+func retryable(stderr string) bool {
+	stderr = strings.ToLower(stderr)
+
+	// check if the error will not resolve by retrying
+	for _, e := range permanentErrors {
+		if strings.Contains(stderr, e) {
+			return false
+		}
+	}
+
+	// check if the error is expected to resolve on its own
+	for _, e := range retryableErrors {
+		if strings.Contains(stderr, e) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,236 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeDocker replaces the Docker CLI with a script that fails with the
+// provided stderr until it has been run the provided number of times.
+//
+// The script records the number of times it was run in the returned file.
+func fakeDocker(t *testing.T, failures int, stderr, stdout string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	count := filepath.Join(dir, "count")
+
+	script := `#!/bin/sh
+n=$(cat "` + count + `" 2>/dev/null || echo 0)
+n=$((n + 1))
+echo "$n" > "` + count + `"
+if [ "$n" -le ` + strconv.Itoa(failures) + ` ]; then
+  echo "` + stderr + `" >&2
+  exit 1
+fi
+echo "` + stdout + `"
+`
+
+	fakeDockerScript(t, dir, script)
+
+	return count
+}
+
+// fakeDockerScript replaces the Docker CLI with the provided shell script.
+func fakeDockerScript(t *testing.T, dir, script string) {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("fake docker binary requires a POSIX shell")
+	}
+
+	path := filepath.Join(dir, "docker")
+
+	//nolint:gosec // the script must be executable for the test
+	err := os.WriteFile(path, []byte(script), 0755)
+	if err != nil {
+		t.Fatalf("WriteFile returned err: %v", err)
+	}
+
+	docker := _docker
+	_docker = path
+
+	t.Cleanup(func() {
+		_docker = docker
+	})
+}
+
+// attempts returns the number of times the fake Docker CLI was run.
+func attempts(t *testing.T, count string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(count)
+	if err != nil {
+		return "0"
+	}
+
+	return strings.TrimSpace(string(data))
+}
+
+func TestDocker_Retry_Do(t *testing.T) {
+	// setup types
+	r := &Retry{
+		Attempts: 3,
+		Delay:    time.Millisecond,
+		MaxDelay: 2 * time.Millisecond,
+	}
+
+	// setup tests
+	tests := []struct {
+		failure  bool
+		name     string
+		failures int
+		stderr   string
+		want     string
+	}{
+		{
+			failure:  false,
+			name:     "transient errors",
+			failures: 2,
+			stderr:   "received unexpected HTTP status: 503 Service Unavailable",
+			want:     "3",
+		},
+		{
+			failure:  true,
+			name:     "exhausted attempts",
+			failures: 5,
+			stderr:   "dial tcp: lookup registry: i/o timeout",
+			want:     "3",
+		},
+		{
+			failure:  true,
+			name:     "permanent error",
+			failures: 5,
+			stderr:   "denied: requested access to the resource is denied",
+			want:     "1",
+		},
+		{
+			failure:  true,
+			name:     "unknown error",
+			failures: 5,
+			stderr:   "invalid reference format",
+			want:     "1",
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			count := fakeDocker(t, test.failures, test.stderr, "")
+
+			err := r.Do(t.Context(), nil, "push", func() *exec.Cmd {
+				return exec.CommandContext(t.Context(), _docker, pushAction)
+			})
+
+			if test.failure {
+				if err == nil {
+					t.Errorf("Do should have returned err")
+				}
+			} else if err != nil {
+				t.Errorf("Do returned err: %v", err)
+			}
+
+			got := attempts(t, count)
+			if got != test.want {
+				t.Errorf("Do ran %s attempts, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDocker_Retry_Do_Nil(t *testing.T) {
+	// setup types
+	var r *Retry
+
+	count := fakeDocker(t, 5, "503 Service Unavailable", "")
+
+	err := r.Do(t.Context(), nil, "push", func() *exec.Cmd {
+		return exec.CommandContext(t.Context(), _docker, pushAction)
+	})
+	if err == nil {
+		t.Errorf("Do should have returned err")
+	}
+
+	got := attempts(t, count)
+	if got != "1" {
+		t.Errorf("Do ran %s attempts, want 1", got)
+	}
+}
+
+func TestDocker_Retry_Validate(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		failure bool
+		retry   *Retry
+	}{
+		{
+			failure: false,
+			retry:   &Retry{Attempts: 3, Delay: time.Second, MaxDelay: time.Minute},
+		},
+		{
+			failure: false,
+			retry:   nil,
+		},
+		{
+			failure: true,
+			retry:   &Retry{Attempts: 0},
+		},
+		{
+			failure: true,
+			retry:   &Retry{Attempts: 1, Delay: -time.Second},
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		err := test.retry.Validate()
+
+		if test.failure {
+			if err == nil {
+				t.Errorf("Validate should have returned err for %+v", test.retry)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Validate returned err: %v", err)
+		}
+	}
+}
+
+func TestDocker_retryable(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		stderr string
+		want   bool
+	}{
+		{stderr: "received unexpected HTTP status: 500 Internal Server Error", want: true},
+		{stderr: "error parsing HTTP 502 Bad Gateway response body", want: true},
+		{stderr: "toomanyrequests: You have reached your pull rate limit", want: true},
+		{stderr: "net/http: TLS handshake timeout", want: true},
+		{stderr: "read tcp 10.0.0.1:443: read: connection reset by peer", want: true},
+		{stderr: "unauthorized: authentication required", want: false},
+		{stderr: "denied: requested access to the resource is denied", want: false},
+		{stderr: "Error response from daemon: Get https://registry/v2/: 503 Service Unavailable\nunauthorized", want: false},
+		{stderr: "invalid reference format", want: false},
+		{stderr: "", want: false},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got := retryable(test.stderr)
+
+		if got != test.want {
+			t.Errorf("retryable is %v for %q, want %v", got, test.stderr, test.want)
+		}
+	}
+}
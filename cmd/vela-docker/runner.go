@@ -0,0 +1,69 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"io"
+	"os"
+	"os/exec"
+)
+
+// Runner represents the runner for the commands executed by the plugin.
+type Runner interface {
+	// Run starts the command and waits for it to complete.
+	Run(cmd *exec.Cmd) error
+	// Start starts the command without waiting for it to complete.
+	Start(cmd *exec.Cmd) error
+	// Wait waits for the started command to complete.
+	Wait(cmd *exec.Cmd) error
+	// Signal sends the signal to the started command.
+	Signal(cmd *exec.Cmd, sig os.Signal) error
+	// Stdout returns the writer for the output of the plugin.
+	Stdout() io.Writer
+	// Stderr returns the writer for the errors of the plugin.
+	Stderr() io.Writer
+}
+
+// execRunner runs the commands on the host with the output
+// written to the stdout and stderr of the plugin.
+type execRunner struct{}
+
+// Run starts the command and waits for it to complete.
+func (r *execRunner) Run(cmd *exec.Cmd) error {
+	return cmd.Run()
+}
+
+// Start starts the command without waiting for it to complete.
+func (r *execRunner) Start(cmd *exec.Cmd) error {
+	return cmd.Start()
+}
+
+// Wait waits for the started command to complete.
+func (r *execRunner) Wait(cmd *exec.Cmd) error {
+	return cmd.Wait()
+}
+
+// Signal sends the signal to the started command.
+func (r *execRunner) Signal(cmd *exec.Cmd, sig os.Signal) error {
+	return cmd.Process.Signal(sig)
+}
+
+// Stdout returns the writer for the output of the plugin.
+func (r *execRunner) Stdout() io.Writer {
+	return os.Stdout
+}
+
+// Stderr returns the writer for the errors of the plugin.
+func (r *execRunner) Stderr() io.Writer {
+	return os.Stderr
+}
+
+// runnerOrExec returns the provided runner or the
+// runner for the host when no runner is provided.
+func runnerOrExec(r Runner) Runner {
+	if r == nil {
+		return new(execRunner)
+	}
+
+	return r
+}
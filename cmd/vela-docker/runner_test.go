@@ -0,0 +1,285 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRunner records the commands executed by the plugin
+// and returns the scripted results for the commands.
+type fakeRunner struct {
+	mu sync.Mutex
+	// commands run in the order they were started
+	commands []string
+	// scripted results keyed by the prefix of the command
+	results map[string][]*fakeResult
+	// results for the commands started in the background
+	started map[*exec.Cmd]*fakeResult
+	// closed when a command started in the background is stopped by a signal
+	signaled map[*exec.Cmd]chan struct{}
+	// signals sent to the commands in the order they were sent
+	signals []string
+	// closed when the test completes to stop the commands in the background
+	stopped chan struct{}
+
+	stdout syncBuffer
+	stderr syncBuffer
+}
+
+// fakeResult represents the scripted result of a command.
+type fakeResult struct {
+	// output written to the stdout of the command
+	stdout string
+	// output written to the stderr of the command
+	stderr string
+	// exit code of the command
+	code int
+	// exit code of the command when stopped by a SIGTERM
+	stopCode int
+	// signifies the command only stops when it is killed
+	ignoreTerm bool
+}
+
+// fakeExitError represents a command exiting with a non-zero code.
+type fakeExitError int
+
+// syncBuffer is a buffer safe for concurrent writes.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// newFakeRunner creates a runner returning the provided results
+// for the commands starting with the keys of the results.
+//
+// A command is run with the results for the longest matching prefix
+// in order and the last result is returned for every later run.
+func newFakeRunner(t *testing.T, results map[string][]*fakeResult) *fakeRunner {
+	t.Helper()
+
+	r := &fakeRunner{
+		results:  results,
+		started:  make(map[*exec.Cmd]*fakeResult),
+		signaled: make(map[*exec.Cmd]chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+
+	t.Cleanup(func() {
+		close(r.stopped)
+	})
+
+	return r
+}
+
+// Error returns the exit code of the command.
+func (e fakeExitError) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+// Write writes the bytes to the buffer.
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+// String returns the contents of the buffer.
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+// Run records the command and returns the scripted result.
+func (r *fakeRunner) Run(cmd *exec.Cmd) error {
+	return r.run(cmd, r.record(cmd))
+}
+
+// Start records the command and writes the scripted output.
+func (r *fakeRunner) Start(cmd *exec.Cmd) error {
+	result := r.record(cmd)
+
+	r.mu.Lock()
+	r.started[cmd] = result
+	r.signaled[cmd] = make(chan struct{})
+	r.mu.Unlock()
+
+	r.write(cmd, result)
+
+	return nil
+}
+
+// Wait returns the scripted result of the started command or blocks
+// until the command is stopped by a signal or the test completes.
+func (r *fakeRunner) Wait(cmd *exec.Cmd) error {
+	r.mu.Lock()
+	result := r.started[cmd]
+	signaled := r.signaled[cmd]
+	r.mu.Unlock()
+
+	if result.code != 0 {
+		return fakeExitError(result.code)
+	}
+
+	select {
+	case <-signaled:
+	case <-r.stopped:
+		return nil
+	}
+
+	// check if the command was killed
+	if result.ignoreTerm {
+		return fakeExitError(137)
+	}
+
+	if result.stopCode != 0 {
+		return fakeExitError(result.stopCode)
+	}
+
+	return nil
+}
+
+// Signal records the signal and stops the started command unless
+// the command ignores a SIGTERM and the signal is not a SIGKILL.
+func (r *fakeRunner) Signal(cmd *exec.Cmd, sig os.Signal) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.signals = append(r.signals, sig.String())
+
+	// check if the command ignores the signal
+	if r.started[cmd].ignoreTerm && sig != os.Kill {
+		return nil
+	}
+
+	close(r.signaled[cmd])
+
+	return nil
+}
+
+// Signals returns the signals sent to the commands in the order they were sent.
+func (r *fakeRunner) Signals() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string(nil), r.signals...)
+}
+
+// Stdout returns the writer for the output of the plugin.
+func (r *fakeRunner) Stdout() io.Writer {
+	return &r.stdout
+}
+
+// Stderr returns the writer for the errors of the plugin.
+func (r *fakeRunner) Stderr() io.Writer {
+	return &r.stderr
+}
+
+// Commands returns the commands run in the order they were started.
+func (r *fakeRunner) Commands() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string(nil), r.commands...)
+}
+
+// record captures the command and returns the scripted result for it.
+func (r *fakeRunner) record(cmd *exec.Cmd) *fakeResult {
+	command := strings.Join(append([]string{filepath.Base(cmd.Path)}, cmd.Args[1:]...), " ")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.commands = append(r.commands, command)
+
+	// variable to store the longest prefix matching the command
+	var match string
+
+	for prefix := range r.results {
+		if strings.HasPrefix(command, prefix) && len(prefix) > len(match) {
+			match = prefix
+		}
+	}
+
+	results := r.results[match]
+
+	// check if a result is scripted for the command
+	if len(results) == 0 {
+		return new(fakeResult)
+	}
+
+	// consume the result when more are scripted for later runs
+	if len(results) > 1 {
+		r.results[match] = results[1:]
+	}
+
+	return results[0]
+}
+
+// run writes the scripted output and returns the scripted exit code.
+func (r *fakeRunner) run(cmd *exec.Cmd, result *fakeResult) error {
+	r.write(cmd, result)
+
+	if result.code != 0 {
+		return fakeExitError(result.code)
+	}
+
+	return nil
+}
+
+// write writes the scripted output to the command.
+func (r *fakeRunner) write(cmd *exec.Cmd, result *fakeResult) {
+	if cmd.Stdout != nil {
+		_, _ = io.WriteString(cmd.Stdout, result.stdout)
+	}
+
+	if cmd.Stderr != nil {
+		_, _ = io.WriteString(cmd.Stderr, result.stderr)
+	}
+}
+
+func TestDocker_runnerOrExec(t *testing.T) {
+	// setup types
+	r := newFakeRunner(t, nil)
+
+	if got := runnerOrExec(r); got != r {
+		t.Errorf("runnerOrExec is %v, want the provided runner", got)
+	}
+
+	if _, ok := runnerOrExec(nil).(*execRunner); !ok {
+		t.Errorf("runnerOrExec should default to the exec runner")
+	}
+}
+
+func TestDocker_execCmd_Runner(t *testing.T) {
+	// setup types
+	r := newFakeRunner(t, map[string][]*fakeResult{
+		"docker info": {{stdout: "Server Version: 28.0.0\n", code: 1}},
+	})
+
+	err := execCmd(r, infoCmd(t.Context()))
+	if err == nil {
+		t.Errorf("execCmd should have returned err")
+	}
+
+	want := "$ " + _docker + " info\nServer Version: 28.0.0\n"
+
+	if r.stdout.String() != want {
+		t.Errorf("execCmd output is %q, want %q", r.stdout.String(), want)
+	}
+
+	if got := r.Commands(); len(got) != 1 || got[0] != "docker info" {
+		t.Errorf("execCmd commands are %v, want [docker info]", got)
+	}
+}
@@ -0,0 +1,300 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// Secret represents a secret exposed to the build (only if BuildKit enabled).
+type Secret struct {
+	// enables setting the id of the secret referenced in the Dockerfile
+	ID string `json:"id"`
+	// enables setting a file to read the secret from
+	Src string `json:"src"`
+	// enables setting an environment variable to read the secret from
+	Env string `json:"env"`
+	// enables setting the type of the secret source - options (file|env)
+	Type string `json:"type"`
+	// enables setting the name of a Vela secret to read the secret from
+	Secret string `json:"secret"`
+
+	// path to the file the Vela secret was written to
+	path string
+}
+
+// secretsPath is the directory where Vela secrets are mounted for the plugin.
+const secretsPath = "/vela/secrets/docker"
+
+// parseSecrets captures the secrets from a JSON list of strings
+// and objects or a comma-separated list of secret strings.
+func parseSecrets(raw string) ([]*Secret, error) {
+	raw = strings.TrimSpace(raw)
+
+	// check if any secrets are provided
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	// check if the secrets are not provided as a JSON list
+	//
+	// a list of strings is provided to the plugin as a comma-separated value
+	if !strings.HasPrefix(raw, "[") {
+		// split the value into the string form of every secret
+		specs, err := parseList(raw, "id")
+		if err != nil {
+			return nil, err
+		}
+
+		// variable to store the captured secrets
+		secrets := make([]*Secret, 0, len(specs))
+
+		for _, spec := range specs {
+			s, err := parseSecret(spec)
+			if err != nil {
+				return nil, err
+			}
+
+			secrets = append(secrets, s)
+		}
+
+		return secrets, nil
+	}
+
+	// variable to store the raw entries of the list
+	var entries []json.RawMessage
+
+	err := json.Unmarshal([]byte(raw), &entries)
+	if err != nil {
+		return nil, fmt.Errorf("unable to unmarshal secrets: %w", err)
+	}
+
+	// variable to store the captured secrets
+	secrets := make([]*Secret, 0, len(entries))
+
+	for i, entry := range entries {
+		// variable to store an entry in the string form
+		var spec string
+
+		// check if the entry is provided in the string form
+		if json.Unmarshal(entry, &spec) == nil {
+			s, err := parseSecret(spec)
+			if err != nil {
+				return nil, err
+			}
+
+			secrets = append(secrets, s)
+
+			continue
+		}
+
+		s := new(Secret)
+
+		err = json.Unmarshal(entry, s)
+		if err != nil {
+			return nil, fmt.Errorf("unable to unmarshal secrets[%d]: %w", i, err)
+		}
+
+		secrets = append(secrets, s)
+	}
+
+	return secrets, nil
+}
+
+// parseSecret captures the secret from the format id=mysecret[,src=/local/secret|env=VAR].
+func parseSecret(spec string) (*Secret, error) {
+	s := new(Secret)
+
+	for _, field := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || len(value) == 0 {
+			return nil, invalidParameter("secrets", spec, fmt.Sprintf("invalid field %q - must be in the format key=value", field))
+		}
+
+		// capture the value for the key
+		switch strings.ToLower(key) {
+		case "id":
+			s.ID = value
+		case "src", "source":
+			s.Src = value
+		case "env":
+			s.Env = value
+		case "type":
+			s.Type = value
+		default:
+			return nil, invalidParameter("secrets", spec, fmt.Sprintf("invalid key %q - options (id|src|env|type)", key))
+		}
+	}
+
+	return s, nil
+}
+
+// Flag formats the secret for the --secret flag.
+func (s *Secret) Flag() string {
+	// variable to store fields for the flag
+	fields := []string{"id=" + s.ID}
+
+	// check if a source file is provided
+	if len(s.Src) > 0 {
+		fields = append(fields, "src="+s.Src)
+	}
+
+	// check if an environment variable is provided
+	if len(s.Env) > 0 {
+		fields = append(fields, "env="+s.Env)
+	}
+
+	// check if a type is provided
+	if len(s.Type) > 0 {
+		fields = append(fields, "type="+s.Type)
+	}
+
+	return strings.Join(fields, ",")
+}
+
+// String returns the secret for error messages.
+func (s *Secret) String() string {
+	// check if a Vela secret is provided
+	if len(s.Secret) > 0 {
+		return fmt.Sprintf("id=%s,secret=%s", s.ID, s.Secret)
+	}
+
+	return s.Flag()
+}
+
+// Validate verifies the Secret is properly configured.
+func (s *Secret) Validate() error {
+	// verify the id is provided
+	if len(s.ID) == 0 {
+		return invalidParameter("secrets", s.String(), "no id provided")
+	}
+
+	// verify the id is valid
+	if !idRegex.MatchString(s.ID) {
+		return invalidParameter("secrets", s.String(), fmt.Sprintf("invalid id %q", s.ID))
+	}
+
+	// variable to store the number of sources provided
+	sources := 0
+
+	for _, source := range []string{s.Src, s.Env, s.Secret} {
+		if len(source) > 0 {
+			sources++
+		}
+	}
+
+	// verify only one source is provided
+	if sources > 1 {
+		return invalidParameter("secrets", s.String(), "only one of src, env or secret can be provided")
+	}
+
+	// verify the Vela secret name stays within the directory for the secrets
+	if strings.ContainsAny(s.Secret, `/\`) || strings.Contains(s.Secret, "..") {
+		return invalidParameter("secrets", s.String(), fmt.Sprintf("invalid secret %q - the name can not contain a path", s.Secret))
+	}
+
+	// verify the type is valid
+	if len(s.Type) > 0 && s.Type != "file" && s.Type != "env" {
+		return invalidParameter("secrets", s.String(), fmt.Sprintf("invalid type %q - options (file|env)", s.Type))
+	}
+
+	return nil
+}
+
+// write creates a file with 0600 permissions containing the value
+// of the Vela secret and sets it as the source of the secret.
+func (s *Secret) write() error {
+	// check if a Vela secret is provided
+	if len(s.Secret) == 0 {
+		return nil
+	}
+
+	logrus.Tracef("writing Vela secret %s for build secret %s", s.Secret, s.ID)
+
+	value, err := s.value()
+	if err != nil {
+		return err
+	}
+
+	// redact the value of the Vela secret from the output
+	secretMask.add(value)
+
+	// use custom filesystem which enables us to test
+	a := &afero.Afero{
+		Fs: appFS,
+	}
+
+	f, err := a.TempFile("", "vela-docker-secret-*")
+	if err != nil {
+		return fmt.Errorf("unable to create file for secret %s: %w", s.ID, err)
+	}
+	defer f.Close()
+
+	// track the file before writing so it is always removed
+	s.path = f.Name()
+	s.Src = f.Name()
+
+	err = a.Chmod(f.Name(), 0600)
+	if err != nil {
+		return fmt.Errorf("unable to set permissions for secret %s: %w", s.ID, err)
+	}
+
+	_, err = f.WriteString(value)
+	if err != nil {
+		return fmt.Errorf("unable to write file for secret %s: %w", s.ID, err)
+	}
+
+	return nil
+}
+
+// remove deletes the file created for the Vela secret.
+func (s *Secret) remove() {
+	// check if a file was created for the secret
+	if len(s.path) == 0 {
+		return
+	}
+
+	logrus.Tracef("removing file for build secret %s", s.ID)
+
+	err := appFS.Remove(s.path)
+	if err != nil {
+		logrus.Warnf("unable to remove file for secret %s: %v", s.ID, err)
+	}
+
+	s.path = ""
+}
+
+// value returns the value of the Vela secret from the environment
+// or the file the secret is mounted to for the plugin.
+func (s *Secret) value() (string, error) {
+	// iterate through the environment variables the secret may be injected as
+	for _, name := range []string{s.Secret, strings.ToUpper(s.Secret)} {
+		if value, ok := os.LookupEnv(name); ok {
+			return value, nil
+		}
+	}
+
+	// use custom filesystem which enables us to test
+	a := &afero.Afero{
+		Fs: appFS,
+	}
+
+	path := filepath.Join(secretsPath, s.Secret)
+
+	// check if the file is within the directory for the secrets
+	if filepath.Dir(path) == secretsPath {
+		data, err := a.ReadFile(path)
+		if err == nil {
+			return string(data), nil
+		}
+	}
+
+	return "", fmt.Errorf("no value found for Vela secret %s used by build secret %s", s.Secret, s.ID)
+}
@@ -0,0 +1,306 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestDocker_parseSecrets(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		failure bool
+		raw     string
+		want    []*Secret
+	}{
+		{
+			failure: false,
+			raw:     "id=npm,src=/root/.npmrc",
+			want:    []*Secret{{ID: "npm", Src: "/root/.npmrc"}},
+		},
+		{
+			// list of strings provided as a comma-separated value
+			failure: false,
+			raw:     "id=npm,src=/root/.npmrc,id=pip,env=PIP_INDEX_URL",
+			want: []*Secret{
+				{ID: "npm", Src: "/root/.npmrc"},
+				{ID: "pip", Env: "PIP_INDEX_URL"},
+			},
+		},
+		{
+			failure: false,
+			raw:     `["id=npm,source=/root/.npmrc", {"id": "pip", "secret": "pip_index_url"}]`,
+			want: []*Secret{
+				{ID: "npm", Src: "/root/.npmrc"},
+				{ID: "pip", Secret: "pip_index_url"},
+			},
+		},
+		{
+			failure: false,
+			raw:     "",
+		},
+		{
+			failure: true,
+			raw:     "foo",
+		},
+		{
+			failure: true,
+			raw:     "id=npm,dest=/tmp/npmrc",
+		},
+		{
+			failure: true,
+			raw:     `[{"id": 1}]`,
+		},
+		{
+			failure: true,
+			raw:     `["id=npm,foo"]`,
+		},
+		{
+			failure: true,
+			raw:     `[`,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got, err := parseSecrets(test.raw)
+
+		if test.failure {
+			if err == nil {
+				t.Errorf("parseSecrets should have returned err for %s", test.raw)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("parseSecrets returned err: %v", err)
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseSecrets is %v, want %v", got, test.want)
+		}
+	}
+}
+
+func TestDocker_Secret_Validate(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		failure bool
+		secret  *Secret
+	}{
+		{
+			failure: false,
+			secret:  &Secret{ID: "npm", Src: "/root/.npmrc"},
+		},
+		{
+			failure: false,
+			secret:  &Secret{ID: "token", Env: "TOKEN"},
+		},
+		{
+			failure: false,
+			secret:  &Secret{ID: "token", Type: "env"},
+		},
+		{
+			failure: false,
+			secret:  &Secret{ID: "pip", Secret: "pip_index_url"},
+		},
+		{
+			failure: true,
+			secret:  &Secret{Src: "/root/.npmrc"},
+		},
+		{
+			failure: true,
+			secret:  &Secret{ID: "-npm"},
+		},
+		{
+			failure: true,
+			secret:  &Secret{ID: "npm", Src: "/root/.npmrc", Env: "NPM_TOKEN"},
+		},
+		{
+			failure: true,
+			secret:  &Secret{ID: "npm", Env: "NPM_TOKEN", Secret: "npm_token"},
+		},
+		{
+			failure: true,
+			secret:  &Secret{ID: "npm", Type: "foo"},
+		},
+		{
+			failure: true,
+			secret:  &Secret{ID: "npm", Secret: "../../../etc/shadow"},
+		},
+		{
+			failure: true,
+			secret:  &Secret{ID: "npm", Secret: "npm/token"},
+		},
+		{
+			failure: true,
+			secret:  &Secret{ID: "npm", Secret: ".."},
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		err := test.secret.Validate()
+
+		if test.failure {
+			if err == nil {
+				t.Errorf("Validate should have returned err for %s", test.secret)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Validate returned err: %v", err)
+		}
+	}
+}
+
+func TestDocker_Secret_write(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup environment
+	t.Setenv("NPM_TOKEN", "superSecretToken")
+
+	// setup types
+	s := &Secret{
+		ID:     "npm",
+		Secret: "npm_token",
+	}
+
+	err := s.write()
+	if err != nil {
+		t.Fatalf("write returned err: %v", err)
+	}
+
+	if len(s.Src) == 0 || s.Flag() != "id=npm,src="+s.Src {
+		t.Errorf("write flag is %s, want src set to the written file", s.Flag())
+	}
+
+	info, err := appFS.Stat(s.Src)
+	if err != nil {
+		t.Fatalf("Stat returned err: %v", err)
+	}
+
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("write permissions are %v, want 0600", info.Mode().Perm())
+	}
+
+	data, err := afero.ReadFile(appFS, s.Src)
+	if err != nil {
+		t.Errorf("ReadFile returned err: %v", err)
+	}
+
+	if string(data) != "superSecretToken" {
+		t.Errorf("write contents are %s, want superSecretToken", data)
+	}
+
+	path := s.Src
+
+	s.remove()
+
+	exists, _ := afero.Exists(appFS, path)
+	if exists {
+		t.Errorf("remove should have deleted %s", path)
+	}
+}
+
+func TestDocker_Secret_write_File(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	err := afero.WriteFile(appFS, "/vela/secrets/docker/pip_index_url", []byte("https://pypi.example.com"), 0600)
+	if err != nil {
+		t.Fatalf("WriteFile returned err: %v", err)
+	}
+
+	// setup types
+	s := &Secret{
+		ID:     "pip",
+		Secret: "pip_index_url",
+	}
+
+	err = s.write()
+	if err != nil {
+		t.Fatalf("write returned err: %v", err)
+	}
+	defer s.remove()
+
+	data, err := afero.ReadFile(appFS, s.Src)
+	if err != nil {
+		t.Errorf("ReadFile returned err: %v", err)
+	}
+
+	if string(data) != "https://pypi.example.com" {
+		t.Errorf("write contents are %s, want https://pypi.example.com", data)
+	}
+}
+
+func TestDocker_Secret_write_Missing(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	s := &Secret{
+		ID:     "npm",
+		Secret: "vela_docker_missing_secret",
+	}
+
+	err := s.write()
+	if err == nil {
+		t.Errorf("write should have returned err")
+	}
+}
+
+func TestDocker_Secret_write_Traversal(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	err := afero.WriteFile(appFS, "/vela/secrets/shadow", []byte("superSecretShadow"), 0600)
+	if err != nil {
+		t.Fatalf("WriteFile returned err: %v", err)
+	}
+
+	// setup types
+	s := &Secret{
+		ID:     "npm",
+		Secret: "../shadow",
+	}
+
+	err = s.write()
+	if err == nil {
+		t.Errorf("write should have returned err")
+	}
+
+	if len(s.Src) > 0 {
+		t.Errorf("write should not have read a file outside of %s", secretsPath)
+	}
+}
+
+func TestDocker_Build_Exec_RemovesSecrets(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup environment
+	t.Setenv("NPM_TOKEN", "superSecretToken")
+
+	// setup types
+	b := &Build{
+		CPU:     &CPU{},
+		Label:   &Label{},
+		Secrets: []*Secret{{ID: "npm", Secret: "npm_token"}},
+	}
+
+	// the build fails without a docker binary
+	_ = b.Exec(t.Context())
+
+	exists, _ := afero.Exists(appFS, b.Secrets[0].Src)
+	if len(b.Secrets[0].Src) == 0 || exists {
+		t.Errorf("Exec should have removed the file for the secret %s", b.Secrets[0].Src)
+	}
+}
@@ -0,0 +1,278 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// latestTag is the tag added for a push to the default branch.
+	latestTag = "latest"
+
+	// maxTagLength is the maximum length of a Docker tag.
+	maxTagLength = 128
+
+	// shortCommitLength is the length of the commit sha used as a tag.
+	shortCommitLength = 7
+
+	// pushEvent is the Vela event for a push to a branch.
+	pushEvent = "push"
+
+	// tagEvent is the Vela event for a pushed tag.
+	tagEvent = "tag"
+)
+
+// TagContext represents the Vela build metadata available to tag templates.
+type TagContext struct {
+	// author from the source commit (VELA_BUILD_AUTHOR_EMAIL)
+	Author string
+	// branch from the source commit (VELA_BUILD_BRANCH)
+	Branch string
+	// build number from vela (VELA_BUILD_NUMBER)
+	BuildNumber int
+	// commit sha from the source commit (VELA_BUILD_COMMIT)
+	Commit string
+	// default branch of the repository (VELA_REPO_BRANCH)
+	DefaultBranch string
+	// event that triggered the build (VELA_BUILD_EVENT)
+	Event string
+	// full name of the repository (VELA_REPO_FULL_NAME)
+	Repo string
+	// tag from the source commit (VELA_BUILD_TAG)
+	Tag string
+}
+
+var (
+	// invalidTagRegex represents the characters that are not valid in a Docker tag.
+	invalidTagRegex = regexp.MustCompile(`[^\w.-]+`)
+
+	// timeNow returns the current time for tag templates.
+	//
+	// This is a variable to enable setting the time in tests.
+	timeNow = time.Now
+
+	// tagFuncs represents the functions available to tag templates.
+	tagFuncs = template.FuncMap{
+		// sanitize converts the value into valid Docker tag syntax
+		"sanitize": sanitizeTag,
+		// trunc shortens the value to the provided length
+		"trunc": func(length int, s string) string {
+			return truncate(s, length)
+		},
+		// now formats the current time in UTC with the provided layout
+		"now": func(layout string) string {
+			return timeNow().UTC().Format(layout)
+		},
+	}
+)
+
+// renderTags renders every tag containing a template
+// against the Vela build metadata.
+//
+// The returned map records the template each tag was rendered from.
+func (l *Label) renderTags(tags []string) ([]string, map[string]string, error) {
+	// variables to store the rendered tags
+	rendered := make([]string, 0, len(tags))
+	templates := make(map[string]string)
+
+	for _, t := range tags {
+		// check if the tag contains a template
+		if !strings.Contains(t, "{{") {
+			rendered = append(rendered, t)
+
+			continue
+		}
+
+		tmpl, err := template.New("tag").Funcs(tagFuncs).Option("missingkey=error").Parse(t)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to parse tag template %q: %w", t, err)
+		}
+
+		b := new(strings.Builder)
+
+		err = tmpl.Execute(b, l.tagContext())
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to render tag template %q: %w", t, err)
+		}
+
+		tag := strings.TrimSpace(b.String())
+
+		// skip a template that rendered empty (e.g. {{ .Tag }} for a push)
+		if len(tag) == 0 {
+			logrus.Debugf("skipping tag template %q which rendered empty", t)
+
+			continue
+		}
+
+		logrus.Debugf("rendered tag template %q as %s", t, tag)
+
+		rendered = append(rendered, tag)
+		templates[tag] = t
+	}
+
+	return rendered, templates, nil
+}
+
+// tagContext creates the context for tag templates from the Vela build metadata.
+func (l *Label) tagContext() *TagContext {
+	// check if any build metadata is provided
+	if l == nil {
+		return new(TagContext)
+	}
+
+	return &TagContext{
+		Author:        l.AuthorEmail,
+		Branch:        l.Branch,
+		BuildNumber:   l.Number,
+		Commit:        l.Commit,
+		DefaultBranch: l.DefaultBranch,
+		Event:         l.Event,
+		Repo:          l.FullName,
+		Tag:           l.Tag,
+	}
+}
+
+// autoTags derives the tags for the image from the Vela build metadata.
+//
+// Every build is tagged with the short commit sha and the build number. A push
+// is tagged with the branch and a push to the default branch adds latest. A tag
+// is expanded into its semantic version components (e.g. 1, 1.2 and 1.2.3).
+func (l *Label) autoTags() []string {
+	// check if any build metadata is provided
+	if l == nil {
+		return nil
+	}
+
+	// variable to store the derived tags
+	var tags []string
+
+	// check if a commit is provided
+	if len(l.Commit) > 0 {
+		tags = append(tags, sanitizeTag(truncate(l.Commit, shortCommitLength)))
+	}
+
+	// check if a build number is provided
+	if l.Number > 0 {
+		tags = append(tags, fmt.Sprintf("build-%d", l.Number))
+	}
+
+	switch l.Event {
+	case pushEvent:
+		// check if a branch is provided
+		if len(l.Branch) > 0 {
+			tags = append(tags, sanitizeTag(l.Branch))
+		}
+
+		// check if the push is to the default branch
+		if len(l.Branch) > 0 && l.Branch == l.DefaultBranch {
+			tags = append(tags, latestTag)
+		}
+	case tagEvent:
+		tags = append(tags, semverTags(l.Tag)...)
+	}
+
+	return tags
+}
+
+// semverTags expands the Git tag into the tags for each component
+// of the semantic version (e.g. v1.2.3 becomes 1, 1.2 and 1.2.3).
+//
+// A pre-release is only tagged with the full version and a tag
+// that is not a semantic version is sanitized and used as-is.
+func semverTags(tag string) []string {
+	// check if a tag is provided
+	if len(tag) == 0 {
+		return nil
+	}
+
+	v, err := semver.StrictNewVersion(strings.TrimPrefix(tag, "v"))
+	if err != nil {
+		logrus.Debugf("tag %s is not a semantic version: %v", tag, err)
+
+		return []string{sanitizeTag(tag)}
+	}
+
+	full := fmt.Sprintf("%d.%d.%d", v.Major(), v.Minor(), v.Patch())
+
+	// check if the version is a pre-release
+	if len(v.Prerelease()) > 0 {
+		return []string{sanitizeTag(full + "-" + v.Prerelease())}
+	}
+
+	// variable to store the expanded tags
+	var tags []string
+
+	// a major version of zero makes no compatibility promise
+	if v.Major() > 0 {
+		tags = append(tags, fmt.Sprintf("%d", v.Major()))
+	}
+
+	return append(tags, fmt.Sprintf("%d.%d", v.Major(), v.Minor()), full)
+}
+
+// sanitizeTag converts the value into valid Docker tag syntax by replacing
+// invalid characters with a dash and truncating it to the maximum length.
+func sanitizeTag(s string) string {
+	s = invalidTagRegex.ReplaceAllString(s, "-")
+
+	// a tag can not start with a period or dash
+	s = strings.TrimLeft(s, ".-")
+
+	return truncate(s, maxTagLength)
+}
+
+// truncate shortens the value to the provided length.
+func truncate(s string, length int) string {
+	// check if the value is longer than the length
+	if len(s) > length {
+		return s[:length]
+	}
+
+	return s
+}
+
+// appendTags adds the tags which are not already present.
+func appendTags(tags []string, add ...string) []string {
+	// variable to store the tags already present
+	seen := make(map[string]bool, len(tags))
+
+	for _, t := range tags {
+		seen[t] = true
+	}
+
+	for _, t := range add {
+		// check if the tag is empty or already present
+		if len(t) == 0 || seen[t] {
+			continue
+		}
+
+		seen[t] = true
+
+		tags = append(tags, t)
+	}
+
+	return tags
+}
+
+// validateTag verifies the tag is a valid Docker tag or image reference.
+func validateTag(tag string) error {
+	// check if the tag is a valid tag on its own
+	if tagRegex.MatchString(tag) {
+		return nil
+	}
+
+	_, err := ParseReference(tag)
+	if err != nil {
+		return fmt.Errorf("invalid tag %q: %w", tag, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,201 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDocker_Label_autoTags(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name  string
+		label *Label
+		want  []string
+	}{
+		{
+			name: "push to default branch",
+			label: &Label{
+				Branch:        "main",
+				Commit:        "48afb5bdc41ad69bf22588491333f7cf71135163",
+				DefaultBranch: "main",
+				Event:         "push",
+				Number:        42,
+			},
+			want: []string{"48afb5b", "build-42", "main", latestTag},
+		},
+		{
+			name: "push to feature branch",
+			label: &Label{
+				Branch:        "feature/Foo_bar",
+				Commit:        "48afb5bdc41ad69bf22588491333f7cf71135163",
+				DefaultBranch: "main",
+				Event:         "push",
+				Number:        42,
+			},
+			want: []string{"48afb5b", "build-42", "feature-Foo_bar"},
+		},
+		{
+			name: "semantic version tag",
+			label: &Label{
+				Commit: "48afb5bdc41ad69bf22588491333f7cf71135163",
+				Event:  "tag",
+				Number: 42,
+				Tag:    "v1.2.3",
+			},
+			want: []string{"48afb5b", "build-42", "1", "1.2", "1.2.3"},
+		},
+		{
+			name: "pull request",
+			label: &Label{
+				Branch: "main",
+				Commit: "48afb5bdc41ad69bf22588491333f7cf71135163",
+				Event:  "pull_request",
+				Number: 42,
+			},
+			want: []string{"48afb5b", "build-42"},
+		},
+		{
+			name:  "no metadata",
+			label: &Label{},
+		},
+		{
+			name: "nil label",
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got := test.label.autoTags()
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("autoTags for %s is %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDocker_semverTags(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		tag  string
+		want []string
+	}{
+		{tag: "v1.2.3", want: []string{"1", "1.2", "1.2.3"}},
+		{tag: "1.2.3", want: []string{"1", "1.2", "1.2.3"}},
+		{tag: "v0.4.1", want: []string{"0.4", "0.4.1"}},
+		{tag: "v1.2.3-rc.1", want: []string{"1.2.3-rc.1"}},
+		{tag: "v1.2.3+build.5", want: []string{"1", "1.2", "1.2.3"}},
+		{tag: "v1.2", want: []string{"v1.2"}},
+		{tag: "release/2024", want: []string{"release-2024"}},
+		{tag: "", want: nil},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got := semverTags(test.tag)
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("semverTags for %s is %v, want %v", test.tag, got, test.want)
+		}
+	}
+}
+
+func TestDocker_sanitizeTag(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "main", want: "main"},
+		{value: "feature/foo", want: "feature-foo"},
+		{value: "dependabot/npm_and_yarn/lodash-4.17.21", want: "dependabot-npm_and_yarn-lodash-4.17.21"},
+		{value: "-.hidden", want: "hidden"},
+		{value: "foo  bar!", want: "foo-bar-"},
+		{value: strings.Repeat("a", 200), want: strings.Repeat("a", maxTagLength)},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got := sanitizeTag(test.value)
+
+		if got != test.want {
+			t.Errorf("sanitizeTag for %s is %s, want %s", test.value, got, test.want)
+		}
+
+		if len(got) > 0 && !tagRegex.MatchString(got) {
+			t.Errorf("sanitizeTag for %s is not a valid tag: %s", test.value, got)
+		}
+	}
+}
+
+func TestDocker_appendTags(t *testing.T) {
+	// setup types
+	want := []string{"latest", "1.2.3", "48afb5b"}
+
+	got := appendTags([]string{"latest", "1.2.3"}, "48afb5b", "latest", "")
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("appendTags is %v, want %v", got, want)
+	}
+}
+
+func TestDocker_Label_renderTags(t *testing.T) {
+	// setup time
+	timeNow = func() time.Time {
+		return time.Date(2025, 7, 4, 12, 0, 0, 0, time.UTC)
+	}
+
+	t.Cleanup(func() {
+		timeNow = time.Now
+	})
+
+	// setup types
+	l := &Label{
+		Branch: "feature/Foo",
+		Commit: "48afb5bdc41ad69bf22588491333f7cf71135163",
+		Event:  "push",
+		Number: 42,
+	}
+
+	tags := []string{
+		"latest",
+		"{{ .Branch | sanitize }}-{{ .Commit | trunc 7 }}",
+		`{{ .BuildNumber }}-{{ now "20060102" }}`,
+		"{{ .Tag }}",
+	}
+
+	want := []string{"latest", "feature-Foo-48afb5b", "42-20250704"}
+
+	got, templates, err := l.renderTags(tags)
+	if err != nil {
+		t.Errorf("renderTags returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("renderTags is %v, want %v", got, want)
+	}
+
+	if templates["feature-Foo-48afb5b"] != tags[1] {
+		t.Errorf("renderTags template is %s, want %s", templates["feature-Foo-48afb5b"], tags[1])
+	}
+}
+
+func TestDocker_Label_renderTags_Error(t *testing.T) {
+	// setup tests
+	tags := []string{
+		"{{ .Branch",
+		"{{ .Unknown }}",
+		"{{ .Branch | unknown }}",
+	}
+
+	// run tests
+	for _, tag := range tags {
+		_, _, err := new(Label).renderTags([]string{tag})
+		if err == nil {
+			t.Errorf("renderTags should have returned err for %s", tag)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+)
+
+// tailWriter is an io.Writer that retains the
+// last lines written to it for error reporting.
+type tailWriter struct {
+	// mutex to synchronize writes from multiple streams
+	mu sync.Mutex
+	// maximum number of lines retained
+	limit int
+	// complete lines retained
+	lines []string
+	// incomplete line waiting for a newline
+	partial []byte
+}
+
+// newTailWriter creates a tailWriter retaining the provided number of lines.
+func newTailWriter(limit int) *tailWriter {
+	return &tailWriter{limit: limit}
+}
+
+// Write captures the provided bytes and discards
+// any lines beyond the configured limit.
+func (t *tailWriter) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// append the bytes to the incomplete line
+	t.partial = append(t.partial, p...)
+
+	// split off every complete line
+	for {
+		i := bytes.IndexByte(t.partial, '\n')
+		if i < 0 {
+			break
+		}
+
+		t.lines = append(t.lines, string(t.partial[:i]))
+		t.partial = t.partial[i+1:]
+	}
+
+	// discard the lines beyond the limit
+	if len(t.lines) > t.limit {
+		t.lines = t.lines[len(t.lines)-t.limit:]
+	}
+
+	return len(p), nil
+}
+
+// String returns the retained lines including
+// any incomplete line written last.
+func (t *tailWriter) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lines := t.lines
+
+	// check if an incomplete line is waiting
+	if len(t.partial) > 0 {
+		lines = append(lines[:len(lines):len(lines)], string(t.partial))
+	}
+
+	// discard the lines beyond the limit
+	if len(lines) > t.limit {
+		lines = lines[len(lines)-t.limit:]
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDocker_tailWriter(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		limit  int
+		writes []string
+		want   string
+	}{
+		{
+			limit:  3,
+			writes: []string{"one\ntwo\n"},
+			want:   "one\ntwo",
+		},
+		{
+			limit:  2,
+			writes: []string{"one\ntwo\n", "three\nfour\n"},
+			want:   "three\nfour",
+		},
+		{
+			limit:  2,
+			writes: []string{"one\ntw", "o\nthr", "ee"},
+			want:   "two\nthree",
+		},
+		{
+			limit:  2,
+			writes: []string{},
+			want:   "",
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		w := newTailWriter(test.limit)
+
+		for _, write := range test.writes {
+			fmt.Fprint(w, write)
+		}
+
+		got := w.String()
+		if got != test.want {
+			t.Errorf("String is %q, want %q", got, test.want)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	// bytesRegex represents the valid syntax for a size in bytes (e.g. 512m, 1.5GB).
+	bytesRegex = regexp.MustCompile(`^\d+(\.\d+)? ?([kKmMgGtTpP][iI]?)?[bB]?$`)
+
+	// hostRegex represents the valid syntax for a hostname.
+	hostRegex = regexp.MustCompile(`^[a-zA-Z0-9](?:[a-zA-Z0-9._-]*[a-zA-Z0-9])?$`)
+
+	// idRegex represents the valid syntax for the id of a secret or SSH component.
+	idRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+	// networkRegex represents the valid syntax for the name of a network.
+	networkRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+	// platformRegex represents the valid syntax for a platform (os/arch[/variant]).
+	platformRegex = regexp.MustCompile(`^[a-z0-9_]+/[a-z0-9_]+(/[a-z0-9_.]+)?$`)
+
+	// outputTypes represents the valid exporters for the output of a build.
+	outputTypes = []string{"cacheonly", "docker", "image", "local", "oci", "registry", "tar"}
+
+	// reservedBuildArgs represents the environment variables of the docker CLI
+	// which can not be overwritten by a build argument forwarded from the environment.
+	reservedBuildArgs = []string{
+		"DOCKER_BUILDKIT", "DOCKER_CERT_PATH", "DOCKER_CONFIG", "DOCKER_CONTEXT",
+		"DOCKER_HOST", "DOCKER_TLS_VERIFY", "HOME", "PATH",
+	}
+
+	// progressTypes represents the valid types of progress output for a build.
+	progressTypes = []string{"auto", "plain", "quiet", "rawjson", "tty"}
+
+	// ulimitNames represents the valid names of a ulimit.
+	ulimitNames = []string{
+		"core", "cpu", "data", "fsize", "locks", "memlock", "msgqueue", "nice",
+		"nofile", "nproc", "rss", "rtprio", "rttime", "sigpending", "stack",
+	}
+)
+
+// invalidParameter formats the error for a parameter with an invalid value.
+func invalidParameter(name, value, reason string) error {
+	return fmt.Errorf("invalid %s value %q: %s", name, value, reason)
+}
+
+// validateAddHost verifies the host-to-IP mapping is in the format host:ip.
+func validateAddHost(s string) error {
+	sep := ":"
+
+	// check if the mapping uses the alternate host=ip format
+	if i := strings.IndexAny(s, "=:"); i >= 0 && s[i] == '=' {
+		sep = "="
+	}
+
+	// split the mapping on the first separator to allow IPv6 addresses
+	host, ip, ok := strings.Cut(s, sep)
+	if !ok {
+		return invalidParameter("add_hosts", s, "must be in the format host:ip")
+	}
+
+	// verify the host is valid
+	if !hostRegex.MatchString(host) {
+		return invalidParameter("add_hosts", s, fmt.Sprintf("invalid host %q", host))
+	}
+
+	// check if the special gateway value is provided
+	if ip == "host-gateway" {
+		return nil
+	}
+
+	// verify the IP address is valid
+	if net.ParseIP(strings.Trim(ip, "[]")) == nil {
+		return invalidParameter("add_hosts", s, fmt.Sprintf("invalid IP address %q", ip))
+	}
+
+	return nil
+}
+
+// validateBuildArg verifies the build-time variable is in the format KEY=VALUE.
+//
+// A KEY without a value is passed from the environment of the plugin.
+func validateBuildArg(s string) error {
+	key, _, _ := strings.Cut(s, "=")
+
+	// verify the key is provided
+	if len(key) == 0 {
+		return invalidParameter("build_args", s, "must be in the format KEY=VALUE")
+	}
+
+	// verify the key does not contain whitespace
+	if strings.ContainsAny(key, " \t\n") {
+		return invalidParameter("build_args", s, fmt.Sprintf("invalid key %q", key))
+	}
+
+	return nil
+}
+
+// validateEnvBuildArg verifies the build argument forwarded from
+// the environment does not overwrite a variable used by the docker CLI.
+//
+// The build arguments are provided through the environment of the docker CLI.
+func validateEnvBuildArg(s string) error {
+	arg, _, _ := strings.Cut(s, "=")
+
+	// verify the build argument is not reserved
+	if slices.Contains(reservedBuildArgs, arg) {
+		return invalidParameter("build_args_from_env", s, fmt.Sprintf("build argument %s is reserved for the docker CLI", arg))
+	}
+
+	return nil
+}
+
+// validateSecretBuildArg verifies the build argument flagged
+// as secret is one of the build arguments for the image.
+func validateSecretBuildArg(name string, args []string) error {
+	// iterate through the build arguments in the format KEY=VALUE
+	for _, a := range args {
+		key, _, _ := strings.Cut(a, "=")
+
+		if key == name {
+			return nil
+		}
+	}
+
+	return invalidParameter("build_args_secret", name, "build argument is not provided")
+}
+
+// validateBytes verifies the value is a size in bytes (e.g. 512m).
+func validateBytes(name, s string, unlimited bool) error {
+	// check if unlimited is allowed for the parameter
+	if unlimited && s == "-1" {
+		return nil
+	}
+
+	// verify the size is valid
+	if !bytesRegex.MatchString(s) {
+		return invalidParameter(name, s, "must be a size in bytes with an optional unit (b|k|m|g)")
+	}
+
+	return nil
+}
+
+// validateUlimit verifies the ulimit is in the format name=soft[:hard].
+func validateUlimit(s string) error {
+	name, limits, ok := strings.Cut(s, "=")
+	if !ok {
+		return invalidParameter("ulimits", s, "must be in the format name=soft[:hard]")
+	}
+
+	// verify the name is valid
+	if !slices.Contains(ulimitNames, name) {
+		return invalidParameter("ulimits", s, fmt.Sprintf("invalid name %q - options (%s)", name, strings.Join(ulimitNames, "|")))
+	}
+
+	soft, hard, ok := strings.Cut(limits, ":")
+	if !ok {
+		hard = soft
+	}
+
+	// verify the soft limit is valid
+	softLimit, err := strconv.ParseInt(soft, 10, 64)
+	if err != nil {
+		return invalidParameter("ulimits", s, fmt.Sprintf("invalid soft limit %q", soft))
+	}
+
+	// verify the hard limit is valid
+	hardLimit, err := strconv.ParseInt(hard, 10, 64)
+	if err != nil {
+		return invalidParameter("ulimits", s, fmt.Sprintf("invalid hard limit %q", hard))
+	}
+
+	// verify the soft limit does not exceed the hard limit
+	if hardLimit >= 0 && (softLimit < 0 || softLimit > hardLimit) {
+		return invalidParameter("ulimits", s, "soft limit must not exceed the hard limit")
+	}
+
+	return nil
+}
+
+// validateSSH verifies the SSH component is in the format default|<id>[=<socket>|<key>[,<key>]].
+func validateSSH(s string) error {
+	id, paths, ok := strings.Cut(s, "=")
+
+	// verify the id is valid
+	if !idRegex.MatchString(id) {
+		return invalidParameter("ssh_components", s, fmt.Sprintf("invalid id %q", id))
+	}
+
+	// check if a socket or keys are provided
+	if !ok {
+		return nil
+	}
+
+	// verify every socket or key is provided
+	for _, path := range strings.Split(paths, ",") {
+		if len(strings.TrimSpace(path)) == 0 {
+			return invalidParameter("ssh_components", s, "empty socket or key path")
+		}
+	}
+
+	return nil
+}
+
+// validateOutput verifies the output is in the format type=<type>[,key=value] or a local path.
+func validateOutput(s string) error {
+	// check if the shorthand for a local directory is provided
+	if !strings.Contains(s, "=") {
+		if len(strings.TrimSpace(s)) == 0 {
+			return invalidParameter("output", s, "must be in the format type=<type>[,key=value]")
+		}
+
+		return nil
+	}
+
+	// variable to store the type of the output
+	var outputType string
+
+	for _, field := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return invalidParameter("output", s, fmt.Sprintf("invalid field %q - must be in the format key=value", field))
+		}
+
+		// check if the field is the type
+		if key == "type" {
+			outputType = value
+		}
+	}
+
+	// verify the type is provided
+	if len(outputType) == 0 {
+		return invalidParameter("output", s, "no type provided")
+	}
+
+	// verify the type is valid
+	if !slices.Contains(outputTypes, outputType) {
+		return invalidParameter("output", s, fmt.Sprintf("invalid type %q - options (%s)", outputType, strings.Join(outputTypes, "|")))
+	}
+
+	return nil
+}
+
+// validateProgress verifies the progress output is a supported type.
+func validateProgress(s string) error {
+	// verify the type is valid
+	if !slices.Contains(progressTypes, s) {
+		return invalidParameter("progress", s, fmt.Sprintf("options (%s)", strings.Join(progressTypes, "|")))
+	}
+
+	return nil
+}
+
+// validateNetwork verifies the networking mode is a valid network name.
+func validateNetwork(s string) error {
+	// verify the network is valid
+	if !networkRegex.MatchString(s) {
+		return invalidParameter("network", s, "must be default, none, host or the name of a network")
+	}
+
+	return nil
+}
+
+// validatePlatform verifies the platform is in the format os/arch[/variant].
+func validatePlatform(name, s string) error {
+	// verify the platform is valid
+	if !platformRegex.MatchString(s) {
+		return invalidParameter(name, s, "must be in the format os/arch[/variant]")
+	}
+
+	return nil
+}
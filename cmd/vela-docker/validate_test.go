@@ -0,0 +1,236 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDocker_validateSyntax(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		failure  bool
+		name     string
+		validate func(string) error
+		values   []string
+	}{
+		{
+			failure:  false,
+			name:     "add_hosts",
+			validate: validateAddHost,
+			values:   []string{"db.company.com:10.0.0.1", "db:::1", "db=10.0.0.1", "host.docker.internal:host-gateway", "db:[::1]"},
+		},
+		{
+			failure:  true,
+			name:     "add_hosts",
+			validate: validateAddHost,
+			values:   []string{"host.company.com", "db:foo", ":10.0.0.1", "-db:10.0.0.1"},
+		},
+		{
+			failure:  false,
+			name:     "build_args",
+			validate: validateBuildArg,
+			values:   []string{"FOO=BAR", "FOO=", "FOO", "foo.bar=a=b"},
+		},
+		{
+			failure:  true,
+			name:     "build_args",
+			validate: validateBuildArg,
+			values:   []string{"=BAR", "", "FOO BAR=baz"},
+		},
+		{
+			failure:  false,
+			name:     "build_args_from_env",
+			validate: validateEnvBuildArg,
+			values:   []string{"NPM_TOKEN", "TOKEN=NPM_TOKEN", "DOCKER_TAG", "HOME_DIR=HOME"},
+		},
+		{
+			failure:  true,
+			name:     "build_args_from_env",
+			validate: validateEnvBuildArg,
+			values:   []string{"HOME", "PATH=BIN_PATH", "DOCKER_HOST=REMOTE_HOST", "DOCKER_CONFIG", "DOCKER_CONTEXT"},
+		},
+		{
+			failure: false,
+			name:    "build_args_secret",
+			validate: func(s string) error {
+				return validateSecretBuildArg(s, []string{"GO_VERSION=1.23", "NPM_TOKEN=superSecretToken"})
+			},
+			values: []string{"NPM_TOKEN", "GO_VERSION"},
+		},
+		{
+			failure: true,
+			name:    "build_args_secret",
+			validate: func(s string) error {
+				return validateSecretBuildArg(s, []string{"GO_VERSION=1.23", "NPM_TOKEN=superSecretToken"})
+			},
+			values: []string{"NPM", "npm_token", ""},
+		},
+		{
+			failure: false,
+			name:    "memory",
+			validate: func(s string) error {
+				return validateBytes("memory", s, false)
+			},
+			values: []string{"1", "512m", "1g", "1.5GB", "2gib", "1024k"},
+		},
+		{
+			failure: true,
+			name:    "memory",
+			validate: func(s string) error {
+				return validateBytes("memory", s, false)
+			},
+			values: []string{"-1", "1x", "m", "1 gigabyte", ""},
+		},
+		{
+			failure: false,
+			name:    "memory_swaps",
+			validate: func(s string) error {
+				return validateBytes("memory_swaps", s, true)
+			},
+			values: []string{"-1", "1g"},
+		},
+		{
+			failure:  false,
+			name:     "ulimits",
+			validate: validateUlimit,
+			values:   []string{"nofile=1024", "nofile=1024:2048", "core=-1", "memlock=-1:-1"},
+		},
+		{
+			failure:  true,
+			name:     "ulimits",
+			validate: validateUlimit,
+			values:   []string{"1", "foo=1", "nofile=a", "nofile=1:b", "nofile=2048:1024", "nofile=-1:1024"},
+		},
+		{
+			failure:  false,
+			name:     "ssh_components",
+			validate: validateSSH,
+			values:   []string{"default", "github=/run/ssh.sock", "keys=/root/.ssh/id_rsa,/root/.ssh/id_ed25519"},
+		},
+		{
+			failure:  true,
+			name:     "ssh_components",
+			validate: validateSSH,
+			values:   []string{"", "=/run/ssh.sock", "default=", "keys=a,,b"},
+		},
+		{
+			failure:  false,
+			name:     "output",
+			validate: validateOutput,
+			values:   []string{"type=local,dest=out", "type=tar,dest=out.tar", "type=registry", "./out"},
+		},
+		{
+			failure:  true,
+			name:     "output",
+			validate: validateOutput,
+			values:   []string{"dest=out", "type=foo", "type=local,dest", " "},
+		},
+		{
+			failure:  false,
+			name:     "progress",
+			validate: validateProgress,
+			values:   []string{"auto", "plain", "tty", "quiet", "rawjson"},
+		},
+		{
+			failure:  true,
+			name:     "progress",
+			validate: validateProgress,
+			values:   []string{"fancy", "Plain"},
+		},
+		{
+			failure:  false,
+			name:     "network",
+			validate: validateNetwork,
+			values:   []string{"default", "none", "host", "my_network.1"},
+		},
+		{
+			failure:  true,
+			name:     "network",
+			validate: validateNetwork,
+			values:   []string{"-host", "my network", "container:foo"},
+		},
+		{
+			failure: false,
+			name:    "platform",
+			validate: func(s string) error {
+				return validatePlatform("platform", s)
+			},
+			values: []string{"linux/amd64", "linux/arm64/v8", "linux/arm/v7", "windows/amd64"},
+		},
+		{
+			failure: true,
+			name:    "platform",
+			validate: func(s string) error {
+				return validatePlatform("platform", s)
+			},
+			values: []string{"linux", "linux/", "linux/amd64/v8/extra", "Linux/AMD64"},
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		for _, value := range test.values {
+			err := test.validate(value)
+
+			if test.failure {
+				if err == nil {
+					t.Errorf("validate %s should have returned err for %q", test.name, value)
+
+					continue
+				}
+
+				// verify the error names the parameter and the value
+				if !strings.Contains(err.Error(), test.name) || !strings.Contains(err.Error(), value) {
+					t.Errorf("validate %s err should name the parameter and value: %v", test.name, err)
+				}
+
+				continue
+			}
+
+			if err != nil {
+				t.Errorf("validate %s returned err: %v", test.name, err)
+			}
+		}
+	}
+}
+
+func TestDocker_Build_Validate_Syntax(t *testing.T) {
+	// setup types
+	b := &Build{
+		AddHosts:      []string{"host.company.com"},
+		BuildArgs:     []string{"=BAR"},
+		CacheFrom:     []string{"type=inline"},
+		CacheTo:       []string{"type=registry"},
+		Context:       ".",
+		Memory:        []string{"1x"},
+		MemorySwaps:   []string{"1y"},
+		Network:       "my network",
+		Output:        "type=foo",
+		Platform:      "linux",
+		Progress:      "fancy",
+		Secrets:       []*Secret{{Src: "/root/.npmrc"}},
+		ShmSizes:      []string{"1z"},
+		SSHComponents: []string{"=/run/ssh.sock"},
+		Tags:          []string{"latest"},
+		Ulimits:       []string{"1"},
+	}
+
+	want := []string{
+		"add_hosts", "build_args", "cache_from", "cache_to", "memory", "memory_swaps", "shm_sizes", "ulimits",
+		"secrets", "ssh_components", "output", "progress", "network", "platform",
+	}
+
+	err := b.Validate()
+	if err == nil {
+		t.Fatalf("Validate should have returned err")
+	}
+
+	// verify every problem is returned at once
+	for _, name := range want {
+		if !strings.Contains(err.Error(), "invalid "+name+" value") {
+			t.Errorf("Validate err should contain %s: %v", name, err)
+		}
+	}
+}
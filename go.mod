@@ -9,6 +9,7 @@ require (
 	github.com/sirupsen/logrus v1.9.3
 	github.com/spf13/afero v1.14.0
 	github.com/urfave/cli/v3 v3.3.8
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (